@@ -0,0 +1,126 @@
+package kvlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"runtime"
+	"time"
+)
+
+// Number of pages copied per step by BackupWithProgress.
+const backupPages = 64
+
+// Writes a consistent copy of the database to destPath using SQLite's online backup.
+// Fails with "database is locked" if the database stays locked for longer than the busy timeout.
+func (s *Store) Backup(destPath string) error {
+	return s.backup(destPath, -1, nil)
+}
+
+// Writes a consistent copy of the database to destPath, calling fn with the pages remaining and total pages after each step.
+func (s *Store) BackupWithProgress(destPath string, fn func(remaining, total int)) error {
+	return s.backup(destPath, backupPages, fn)
+}
+
+// Runs fn with the underlying *sqlite3.SQLiteConn of conn.
+func rawConn(conn *sql.Conn, fn func(c *sqlite3.SQLiteConn) error) error {
+	return conn.Raw(func(driverConn interface{}) error {
+		switch c := driverConn.(type) {
+		case *sqlite3.SQLiteConn:
+			return fn(c)
+		case *logConn:
+			return fn(c.SQLiteConn)
+		default:
+			return fmt.Errorf("kvlite: Unexpected driver connection %T.", driverConn)
+		}
+	})
+}
+
+// Finishes backup b, clearing the finalizer the driver leaves set when sqlite3_backup_finish reports an error so b is not finished twice.
+func finishBackup(b *sqlite3.SQLiteBackup) error {
+	err := b.Finish()
+	runtime.SetFinalizer(b, nil)
+	return err
+}
+
+func (s *Store) backup(destPath string, pages int, fn func(remaining, total int)) (err error) {
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
+
+	if destPath == NONE {
+		return fmt.Errorf("kvlite: Missing filename parameter.")
+	}
+
+	ctx := context.Background()
+
+	destDB, err := sql.Open(_Store_DRIVER, destPath)
+	if err != nil {
+		return err
+	}
+	defer destDB.Close()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	srcConn, err := s.dbCon.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	// Wait on a busy or locked source for up to the busy timeout.
+	wait := Options{}.busyTimeout()
+	if s.connector != nil {
+		s.connector.mutex.Lock()
+		wait = s.connector.timeout
+		s.connector.mutex.Unlock()
+	}
+
+	return rawConn(destConn, func(dest *sqlite3.SQLiteConn) error {
+		return rawConn(srcConn, func(src *sqlite3.SQLiteConn) error {
+			b, err := dest.Backup("main", src, "main")
+			if err != nil {
+				return err
+			}
+
+			deadline := time.Now().Add(wait)
+
+			for {
+				before := b.Remaining()
+
+				done, err := b.Step(pages)
+				if err != nil {
+					finishBackup(b)
+					return err
+				}
+				if done {
+					if fn != nil {
+						fn(b.Remaining(), b.PageCount())
+					}
+					break
+				}
+
+				// The driver reports a busy or locked source as a step that copied nothing, give it a moment.
+				if pages < 0 || b.Remaining() == before {
+					if time.Now().After(deadline) {
+						finishBackup(b)
+						return sqlite3.Error{Code: sqlite3.ErrBusy}
+					}
+					time.Sleep(10 * time.Millisecond)
+					continue
+				}
+				deadline = time.Now().Add(wait)
+				if fn != nil {
+					fn(b.Remaining(), b.PageCount())
+				}
+			}
+
+			return finishBackup(b)
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package kvlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Stores value at binary key in table, binary keys match byte for byte rather than without regard to case.
+// A table should hold either binary keys or string keys, not both.
+func (s *Store) SetBinaryKey(table string, key []byte, val interface{}) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return err
+	}
+
+	value, eFlag, err := s.encode(val, 0)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	s.cache.forgetTables(table)
+
+	err = s.retry(ctx, func() (err error) {
+		tx, err := s.dbCon.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				tx.Rollback()
+			}
+		}()
+
+		_, err = tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS '"+table+"' ("+tableDef(key)+");")
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO '"+table+"'(key,value,e,expires_at,updated_at,version) VALUES(?, ?, ?, 0, ?, COALESCE((SELECT version FROM '"+table+"' WHERE key = ?), 0) + 1);", key, value, eFlag, millis(time.Now()), key)
+		if err != nil {
+			return err
+		}
+		if err = s.project(ctx, tx, table, key, val); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return err
+	}
+	s.notifyValue(table, key, EventSet, value, eFlag)
+	return nil
+}
+
+// Retreive a value at binary key in table.
+func (s *Store) GetBinaryKey(table string, key []byte, output interface{}) (found bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		data    []byte
+		eFlag   int
+		expires int64
+	)
+
+	err = s.dbCon.QueryRow("SELECT value, e, "+s.extCol(table, "expires_at")+" FROM '"+table+"' WHERE key = ?;", key).Scan(&data, &eFlag, &expires)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		if isNoTable(err) {
+			return false, nil
+		}
+		return false, err
+	case expired(expires):
+		return false, nil
+	}
+
+	return true, s.decode(table, string(key), data, eFlag, output)
+}
+
+// Unset/remove binary key in table.
+func (s *Store) UnsetBinaryKey(table string, key []byte) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetTables(table)
+
+	result, err := s.dbCon.Exec("DELETE FROM '"+table+"' WHERE key = ?;", key)
+	if err != nil {
+		if isNoTable(err) {
+			return nil
+		}
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		s.notify(table, key, EventUnset)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package kvlite
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Identifies a cached value by table and folded key.
+type cacheKey struct {
+	table string
+	key   string
+}
+
+// Returns the cacheKey of key in table, table names match without regard to case as in SQLite.
+func cacheID(table, key string) cacheKey {
+	return cacheKey{strings.ToLower(table), key}
+}
+
+// A value as stored in the database, still to be decrypted and decoded.
+type cacheEntry struct {
+	id      cacheKey
+	data    []byte
+	eFlag   int
+	expires int64
+}
+
+// valueCache holds the most recently read values, evicting the least recently used beyond max entries.
+// A nil *valueCache caches nothing.
+type valueCache struct {
+	mutex   sync.Mutex
+	max     int
+	order   *list.List
+	entries map[cacheKey]*list.Element
+}
+
+func newValueCache(max int) *valueCache {
+	return &valueCache{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[cacheKey]*list.Element),
+	}
+}
+
+// Returns the cached value of key in table.
+func (c *valueCache) get(table, key string) (entry *cacheEntry, ok bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.entries[cacheID(table, key)]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry), true
+}
+
+// Caches the stored value of key in table.
+func (c *valueCache) add(table, key string, data []byte, eFlag int, expires int64) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	id := cacheID(table, key)
+	if elem, ok := c.entries[id]; ok {
+		c.order.Remove(elem)
+	}
+	c.entries[id] = c.order.PushFront(&cacheEntry{id, data, eFlag, expires})
+
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).id)
+	}
+}
+
+// Drops the cached value of key in table.
+func (c *valueCache) forget(table, key string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	id := cacheID(table, key)
+	if elem, ok := c.entries[id]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, id)
+	}
+}
+
+// Drops all cached values of tables, or of every table if none are specified.
+func (c *valueCache) forgetTables(tables ...string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for id, elem := range c.entries {
+		if len(tables) > 0 && !hasTable(tables, id.table) {
+			continue
+		}
+		c.order.Remove(elem)
+		delete(c.entries, id)
+	}
+}
+
+// Returns true if table is in tables.
+func hasTable(tables []string, table string) bool {
+	for _, t := range tables {
+		if strings.EqualFold(t, table) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enables caching of up to maxEntries values read by Get, so repeated reads of a key skip the database.
+// Values are cached as stored and are still decrypted and decoded on each Get, a maxEntries of 0 or less disables the cache.
+// The cache is kept current with writes made through the Store, writes made by other processes or Stores to the same file are not seen.
+func (s *Store) EnableCache(maxEntries int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if maxEntries <= 0 {
+		s.cache = nil
+		return
+	}
+	s.cache = newValueCache(maxEntries)
+}
+
+// Returns the cache key of key as the Store matches keys.
+func (s *Store) cacheID(key interface{}) string {
+	return s.foldKey(fmt.Sprintf("%v", key))
+}
+
+// Drops the cached value of key in table, if any.
+func (s *Store) uncache(table string, key interface{}) {
+	if s.cache != nil {
+		s.cache.forget(table, s.cacheID(key))
+	}
+}
+
+// Caches a value read from table, values of reserved tables are never cached.
+func (s *Store) cacheValue(table string, key interface{}, data []byte, eFlag int, expires int64) {
+	if s.cache != nil && !isReserved(table) {
+		s.cache.add(table, s.cacheID(key), data, eFlag, expires)
+	}
+}
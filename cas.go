@@ -0,0 +1,551 @@
+package kvlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Runs fn on a single connection within a BEGIN IMMEDIATE transaction, committing if fn returns nil.
+func (s *Store) immediate(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	conn, err := s.dbCon.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "BEGIN IMMEDIATE;"); err != nil {
+		return err
+	}
+
+	if err = fn(conn); err == nil {
+		_, err = conn.ExecContext(ctx, "COMMIT;")
+	}
+	if err != nil {
+		conn.ExecContext(context.Background(), "ROLLBACK;")
+	}
+	return
+}
+
+// Writes new at key only if the current value matches old, an old of nil matches a missing key.
+func (s *Store) CompareAndSwap(table, key string, old, new interface{}) (swapped bool, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return false, err
+	}
+
+	newBytes, codecFlag, err := s.marshal(new)
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		value []byte
+		eFlag int
+	)
+
+	ctx := context.Background()
+
+	err = s.immediate(ctx, func(conn *sql.Conn) (err error) {
+		var match bool
+		eFlag, match, err = s.compare(ctx, conn, table, key, old)
+		if err != nil || !match {
+			return err
+		}
+		if eFlag, err = s.rewriteFlag(codecFlag, eFlag); err != nil {
+			return err
+		}
+		if value, err = s.sealValue(newBytes, eFlag); err != nil {
+			return err
+		}
+		if err = s.put(ctx, conn, table, key, value, eFlag, 0); err != nil {
+			return err
+		}
+		if err = s.project(ctx, conn, table, key, new); err != nil {
+			return err
+		}
+		swapped = true
+		return nil
+	})
+	if swapped && err == nil {
+		s.notifyValue(table, key, EventSet, value, eFlag)
+	}
+	return swapped, err
+}
+
+// Removes key only if the current value matches old.
+func (s *Store) CompareAndDelete(table, key string, old interface{}) (deleted bool, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+
+	err = s.immediate(ctx, func(conn *sql.Conn) error {
+		_, match, err := s.compare(ctx, conn, table, key, old)
+		if err != nil || !match || old == nil {
+			return err
+		}
+		s.uncache(table, key)
+		if _, err = conn.ExecContext(ctx, "DELETE FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ?;", key); err != nil {
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	if deleted && err == nil {
+		s.notify(table, key, EventUnset)
+	}
+	return deleted, err
+}
+
+// Compares the current value at key against old, returning the e flag of the current value.
+func (s *Store) compare(ctx context.Context, db dbExec, table, key string, old interface{}) (eFlag int, match bool, err error) {
+	data, eFlag, found, err := s.fetch(ctx, db, table, key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if old == nil || !found {
+		return eFlag, old == nil && !found, nil
+	}
+
+	oldBytes, _, err := s.marshal(old)
+	if err != nil {
+		return 0, false, err
+	}
+
+	raw, err := s.unseal(data, eFlag)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return eFlag, bytes.Equal(raw, oldBytes), nil
+}
+
+// ErrNotInteger is returned if Increment is used on a key whose value is not an integer.
+var ErrNotInteger = errors.New("kvlite: Existing value is not an integer, unable to increment.")
+
+// Atomically adds delta to the integer stored at key, a missing key is treated as 0, returns the new value.
+func (s *Store) Increment(table, key string, delta int64) (value int64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		stored []byte
+		stFlag int
+	)
+
+	ctx := context.Background()
+
+	err = s.immediate(ctx, func(conn *sql.Conn) error {
+		data, eFlag, found, err := s.fetch(ctx, conn, table, key)
+		if err != nil {
+			return err
+		}
+
+		raw, err := s.unseal(data, eFlag)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !found:
+		case eFlag&(eGob|eCodec) != 0:
+			if err = s.unmarshal(raw, eFlag, &value); err != nil {
+				return ErrNotInteger
+			}
+		default:
+			var num json.Number
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.UseNumber()
+			if err = dec.Decode(&num); err != nil {
+				return ErrNotInteger
+			}
+			if value, err = num.Int64(); err != nil {
+				return ErrNotInteger
+			}
+		}
+
+		value = value + delta
+
+		raw, codecFlag, err := s.marshal(value)
+		if err != nil {
+			return err
+		}
+		if eFlag, err = s.rewriteFlag(codecFlag, eFlag); err != nil {
+			return err
+		}
+		if stored, err = s.sealValue(raw, eFlag); err != nil {
+			return err
+		}
+		stFlag = eFlag
+		if err = s.put(ctx, conn, table, key, stored, eFlag, 0); err != nil {
+			return err
+		}
+		return s.project(ctx, conn, table, key, value)
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.notifyValue(table, key, EventSet, stored, stFlag)
+	return value, nil
+}
+
+// Decodes the value at key into out, if key is missing stores the result of compute first, returns true if compute was called.
+func (s *Store) GetOrSet(table, key string, out interface{}, compute func() (interface{}, error)) (created bool, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		stored []byte
+		stFlag int
+	)
+
+	ctx := context.Background()
+
+	err = s.immediate(ctx, func(conn *sql.Conn) error {
+		data, eFlag, found, err := s.fetch(ctx, conn, table, key)
+		if err != nil {
+			return err
+		}
+
+		if found {
+			return s.decode(table, key, data, eFlag, out)
+		}
+
+		val, err := compute()
+		if err != nil {
+			return err
+		}
+
+		raw, codecFlag, err := s.marshal(val)
+		if err != nil {
+			return err
+		}
+		eFlag, err = s.rewriteFlag(codecFlag, 0)
+		if err != nil {
+			return err
+		}
+		if stored, err = s.sealValue(raw, eFlag); err != nil {
+			return err
+		}
+		stFlag = eFlag
+		if err = s.put(ctx, conn, table, key, stored, eFlag, 0); err != nil {
+			return err
+		}
+		if err = s.project(ctx, conn, table, key, val); err != nil {
+			return err
+		}
+		created = true
+		return s.unmarshal(raw, codecFlag, out)
+	})
+	if err != nil {
+		return false, err
+	}
+	if created {
+		s.notifyValue(table, key, EventSet, stored, stFlag)
+	}
+	return created, nil
+}
+
+// Stores value at key only if key does not already exist, returns true if the value was written.
+func (s *Store) SetIfAbsent(table, key string, val interface{}) (written bool, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return false, err
+	}
+
+	value, eFlag, err := s.encode(val, 0)
+	if err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+
+	err = s.immediate(ctx, func(conn *sql.Conn) error {
+		_, _, found, err := s.fetch(ctx, conn, table, key)
+		if err != nil || found {
+			return err
+		}
+		if err = s.put(ctx, conn, table, key, value, eFlag, 0); err != nil {
+			return err
+		}
+		if err = s.project(ctx, conn, table, key, val); err != nil {
+			return err
+		}
+		written = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if written {
+		s.notifyValue(table, key, EventSet, value, eFlag)
+	}
+	return written, nil
+}
+
+// Stores value at key only if key already exists, returns true if the value was written.
+func (s *Store) SetIfPresent(table, key string, val interface{}) (written bool, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return false, err
+	}
+
+	value, eFlag, err := s.encode(val, 0)
+	if err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+
+	s.uncache(table, key)
+
+	err = s.immediate(ctx, func(conn *sql.Conn) error {
+		result, err := conn.ExecContext(ctx, "UPDATE '"+table+"' SET value = ?, e = ?, expires_at = 0, updated_at = ?, version = version + 1 WHERE key COLLATE "+s.collate()+" = ? AND (expires_at = 0 OR expires_at > ?);", value, eFlag, millis(time.Now()), key, time.Now().Unix())
+		if err != nil {
+			if isNoTable(err) {
+				return nil
+			}
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil || n == 0 {
+			return err
+		}
+		written = true
+		return s.project(ctx, conn, table, key, val)
+	})
+	if err != nil {
+		return false, err
+	}
+	if written {
+		s.notifyValue(table, key, EventSet, value, eFlag)
+	}
+	return written, nil
+}
+
+// Atomically decodes the value at key in to ptr, calls mutate to modify ptr, then stores ptr back at key.
+// ptr is reset to its zero value first, so it remains zero if key is missing, if mutate returns an error nothing is written.
+func (s *Store) Update(table, key string, ptr interface{}, mutate func() error) (err error) {
+
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("kvlite: Update requires a non-nil pointer, got %T.", ptr)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	var (
+		value []byte
+		eFlag int
+	)
+
+	err = s.immediate(ctx, func(conn *sql.Conn) error {
+		data, flag, found, err := s.fetch(ctx, conn, table, key)
+		if err != nil {
+			return err
+		}
+		eFlag = flag
+
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+
+		if found {
+			if err = s.decode(table, key, data, eFlag, ptr); err != nil {
+				return err
+			}
+		}
+
+		if err = mutate(); err != nil {
+			return err
+		}
+
+		raw, codecFlag, err := s.marshal(ptr)
+		if err != nil {
+			return err
+		}
+		if eFlag, err = s.rewriteFlag(codecFlag, eFlag); err != nil {
+			return err
+		}
+		if value, err = s.sealValue(raw, eFlag); err != nil {
+			return err
+		}
+		if err = s.put(ctx, conn, table, key, value, eFlag, 0); err != nil {
+			return err
+		}
+		return s.project(ctx, conn, table, key, rv.Elem().Interface())
+	})
+	if err != nil {
+		return err
+	}
+	s.notifyValue(table, key, EventSet, value, eFlag)
+	return nil
+}
+
+// Atomically moves key from srcTable to dstTable, replacing any existing key in dstTable, returns false if key is not in srcTable.
+func (s *Store) Move(srcTable, dstTable, key string) (moved bool, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err = s.chkTable(&srcTable, 0); err != nil {
+		return false, err
+	}
+	if err = s.chkTable(&dstTable, 0); err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+
+	var (
+		value interface{}
+		eFlag int
+	)
+
+	err = s.immediate(ctx, func(conn *sql.Conn) error {
+		var expires int64
+
+		err := conn.QueryRowContext(ctx, "SELECT value, e, "+s.extCol(srcTable, "expires_at")+" FROM '"+srcTable+"' WHERE key COLLATE "+s.collate()+" = ?;", key).Scan(&value, &eFlag, &expires)
+		switch {
+		case err == sql.ErrNoRows:
+			return nil
+		case err != nil:
+			if isNoTable(err) {
+				return nil
+			}
+			return err
+		case expired(expires):
+			return nil
+		}
+
+		if err = s.chkProjected(dstTable); err != nil {
+			return err
+		}
+		if err = s.put(ctx, conn, dstTable, key, value, eFlag, expires); err != nil {
+			return err
+		}
+		s.uncache(srcTable, key)
+		if _, err = conn.ExecContext(ctx, "DELETE FROM '"+srcTable+"' WHERE key COLLATE "+s.collate()+" = ?;", key); err != nil {
+			return err
+		}
+		moved = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if moved {
+		s.notify(srcTable, key, EventUnset)
+		s.notifyValue(dstTable, key, EventSet, value, eFlag)
+	}
+	return moved, nil
+}
+
+// ErrKeyExists is returned by RenameKey if the new key is already in use.
+var ErrKeyExists = errors.New("kvlite: Key already exists, unable to rename.")
+
+// Atomically renames oldKey to newKey in table keeping its value, encryption and expiry, returns false if oldKey does not exist.
+// Fails with ErrKeyExists rather than replace an existing newKey.
+func (s *Store) RenameKey(table, oldKey, newKey string) (renamed bool, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+
+	var (
+		value []byte
+		eFlag int
+	)
+
+	err = s.immediate(ctx, func(conn *sql.Conn) (err error) {
+		var found bool
+		value, eFlag, found, err = s.fetch(ctx, conn, table, oldKey)
+		if err != nil || !found {
+			return err
+		}
+
+		// Changing only the case of a key is a rename of the key to itself unless keys are case sensitive.
+		if s.foldKey(oldKey) != s.foldKey(newKey) {
+			_, _, found, err = s.fetch(ctx, conn, table, newKey)
+			if err != nil {
+				return err
+			}
+			if found {
+				return ErrKeyExists
+			}
+			// Clear out an expired newKey.
+			if _, err = conn.ExecContext(ctx, "DELETE FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ?;", newKey); err != nil {
+				return err
+			}
+		}
+
+		s.uncache(table, oldKey)
+		s.uncache(table, newKey)
+		if _, err = conn.ExecContext(ctx, "UPDATE '"+table+"' SET key = ?, updated_at = ?, version = version + 1 WHERE key COLLATE "+s.collate()+" = ?;", newKey, millis(time.Now()), oldKey); err != nil {
+			return err
+		}
+		renamed = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if renamed {
+		s.notify(table, oldKey, EventUnset)
+		s.notifyValue(table, newKey, EventSet, value, eFlag)
+	}
+	return renamed, nil
+}
@@ -0,0 +1,71 @@
+package kvlite
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+// Cipher selects how CryptSet encrypts values.
+type Cipher int
+
+const (
+	CipherCFB Cipher = iota // AES-256-CFB, the original unauthenticated format.
+	CipherGCM               // AES-256-GCM, altered values fail to decrypt with ErrDecryptFailed.
+)
+
+// ErrDecryptFailed is returned if an encrypted value cannot be authenticated with the Store's key.
+var ErrDecryptFailed = errors.New("kvlite: Unable to decrypt value, wrong key or value has been altered.")
+
+// ErrNoKey is returned if an encrypted value is written or read on a Store with no encryption key configured.
+var ErrNoKey = errors.New("kvlite: No encryption key configured, unable to encrypt or decrypt value.")
+
+// Sets the Cipher used to encrypt values on future writes, values are always decrypted with the Cipher they were written with.
+func (s *Store) SetCipher(c Cipher) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cipher = c
+}
+
+// Returns the e column bits for an encrypted value written with the Store's Cipher.
+func (s *Store) cryptFlag() int {
+	if s.cipher == CipherGCM {
+		return eCrypt | eGCM
+	}
+	return eCrypt
+}
+
+// Returns the e column bits for encryption of Stores opened with EncryptByDefault, otherwise 0.
+func (s *Store) defaultCrypt() int {
+	if s.encryptAll {
+		return s.cryptFlag()
+	}
+	return 0
+}
+
+// Returns the e column bits for rewriting a value encoded with codec bits, keeping the encryption and compression of prev.
+func (s *Store) rewriteFlag(codec, prev int) (int, error) {
+	eFlag := codec | prev&(eCrypt|eGCM|eZip) | s.defaultCrypt()
+	if eFlag&eCrypt != 0 && len(s.key) == 0 {
+		return 0, ErrNoKey
+	}
+	return eFlag, nil
+}
+
+// Encrypts raw with key using the Cipher recorded in eFlag.
+func encryptFlag(raw []byte, eFlag int, key []byte) []byte {
+	if eFlag&eGCM != 0 {
+		return encryptGCM(raw, key)
+	}
+	return encrypt(raw, key)
+}
+
+// Decrypts data with key using the Cipher recorded in eFlag.
+func decryptFlag(data []byte, eFlag int, key []byte) ([]byte, error) {
+	if eFlag&eGCM != 0 {
+		return decryptGCM(data, key)
+	}
+	if _, err := base64.RawStdEncoding.DecodeString(string(data)); err != nil {
+		return nil, ErrDecryptFailed
+	}
+	return decrypt(data, key), nil
+}
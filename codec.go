@@ -0,0 +1,234 @@
+package kvlite
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Codec encodes values written to the Store and decodes values read from it.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values as JSON, this is the format used when no Codec is set.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// GobCodec encodes values with encoding/gob.
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buff bytes.Buffer
+	if err := gob.NewEncoder(&buff).Encode(v); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ErrNoCodec is returned if a value written with a custom Codec is read without that Codec set.
+var ErrNoCodec = errors.New("kvlite: Value was stored with a custom codec, use SetCodec before reading.")
+
+// ErrDecode is returned when a stored value cannot be decoded in to the type requested, such as after the type has changed.
+type ErrDecode struct {
+	Table string       // Table of the value.
+	Key   string       // Key of the value.
+	Type  reflect.Type // Type the value was decoded in to.
+	Err   error        // Error returned by the Codec.
+}
+
+func (e *ErrDecode) Error() string {
+	return fmt.Sprintf("kvlite: Unable to decode '%s' in table '%s' in to %v: %v", e.Key, e.Table, e.Type, e.Err)
+}
+
+func (e *ErrDecode) Unwrap() error {
+	return e.Err
+}
+
+// Sets the Codec used to encode values on future writes, values are always decoded with the Codec they were written with.
+func (s *Store) SetCodec(c Codec) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.codec = c
+}
+
+// Returns the e column bits recording Codec c.
+func codecFlag(c Codec) int {
+	switch c.(type) {
+	case nil, JSONCodec, *JSONCodec:
+		return 0
+	case GobCodec, *GobCodec:
+		return eGob
+	default:
+		return eCodec
+	}
+}
+
+// Stored for nil values whatever the Codec, read back as the zero value of the output.
+var nullValue = []byte("null\n")
+
+// Returns true if val is nil or a nil pointer, map, slice, interface, channel or func.
+func isNil(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
+
+// Buffers for encoding values, so concurrent writers need not share one.
+var encodeBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// Encodes val in to its raw unencrypted form, returning the e column bits for the Codec used.
+// Nil values are stored as a JSON null regardless of Codec, as codecs such as gob cannot encode them.
+func (s *Store) marshal(val interface{}) (raw []byte, eFlag int, err error) {
+	if v, ok := val.([]byte); ok {
+		return v, 0, nil
+	}
+
+	if isNil(val) {
+		return append([]byte(nil), nullValue...), 0, nil
+	}
+
+	// Common standard library types are always stored as JSON, which round-trips them whatever the Codec.
+	if v, ok := stdValue(val); ok {
+		if raw, err = json.Marshal(v); err != nil {
+			return nil, 0, err
+		}
+		return append(raw, '\n'), 0, nil
+	}
+
+	if s.codec == nil {
+		buff := encodeBuffers.Get().(*bytes.Buffer)
+		defer encodeBuffers.Put(buff)
+		buff.Reset()
+		if err = json.NewEncoder(buff).Encode(val); err != nil {
+			return nil, 0, err
+		}
+		return append([]byte(nil), buff.Bytes()...), 0, nil
+	}
+
+	raw, err = s.codec.Marshal(val)
+	return raw, codecFlag(s.codec), err
+}
+
+// Decodes raw bytes in to output using the Codec recorded in eFlag.
+func (s *Store) unmarshal(data []byte, eFlag int, output interface{}) error {
+	switch o := output.(type) {
+	case *[]byte:
+		*o = append(*o, data[0:]...)
+		return nil
+	case nil:
+		return nil
+	}
+
+	switch {
+	case eFlag&eGob != 0:
+		return GobCodec{}.Unmarshal(data, output)
+	case eFlag&eCodec != 0:
+		if codecFlag(s.codec) != eCodec {
+			return ErrNoCodec
+		}
+		return s.codec.Unmarshal(data, output)
+	case isRawJSON(output):
+		// JSON is handed back as stored, only checked to be valid, as []byte values are stored unencoded.
+		raw := bytes.TrimSpace(data)
+		if !json.Valid(raw) {
+			return errors.New("kvlite: Stored value is not valid JSON.")
+		}
+		*output.(*json.RawMessage) = append((*output.(*json.RawMessage))[:0], raw...)
+		return nil
+	case isURL(output) && bytes.HasPrefix(data, []byte(`"`)):
+		return unmarshalURL(data, output)
+	default:
+		// A stored nil sets output to its zero value.
+		if string(bytes.TrimSpace(data)) == "null" {
+			if v := reflect.ValueOf(output); v.Kind() == reflect.Ptr && !v.IsNil() {
+				v.Elem().Set(reflect.Zero(v.Elem().Type()))
+				return nil
+			}
+		}
+		return JSONCodec{}.Unmarshal(data, output)
+	}
+}
+
+// Returns val in the form stored as JSON for time.Time, url.URL, net.IP and big.Int values, ok is false for values of other types.
+// A url.URL is stored as its string, as its Userinfo has no exported fields, and a big.Int as a number.
+func stdValue(val interface{}) (value interface{}, ok bool) {
+	switch v := val.(type) {
+	case time.Time, *time.Time, net.IP, *big.Int:
+		return v, true
+	case big.Int:
+		return &v, true
+	case url.URL:
+		return v.String(), true
+	case *url.URL:
+		return v.String(), true
+	}
+	return nil, false
+}
+
+// Returns true if output is a *json.RawMessage.
+func isRawJSON(output interface{}) bool {
+	_, ok := output.(*json.RawMessage)
+	return ok
+}
+
+// Returns true if output is a *url.URL or **url.URL.
+func isURL(output interface{}) bool {
+	switch output.(type) {
+	case *url.URL, **url.URL:
+		return true
+	}
+	return false
+}
+
+// Decodes a url.URL stored as a JSON string in to output.
+func unmarshalURL(data []byte, output interface{}) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	u, err := url.Parse(str)
+	if err != nil {
+		return err
+	}
+	switch o := output.(type) {
+	case *url.URL:
+		*o = *u
+	case **url.URL:
+		*o = u
+	}
+	return nil
+}
+
+// Registers the concrete types of values with encoding/gob, needed to decode interface fields of values stored with GobCodec.
+func (s *Store) Register(values ...interface{}) {
+	for _, v := range values {
+		gob.Register(v)
+	}
+}
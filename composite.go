@@ -0,0 +1,53 @@
+package kvlite
+
+import (
+	"context"
+	"strings"
+)
+
+// Separates the parts of a key built by CompositeKey.
+const keySep = ':'
+
+var keyPartEscaper = strings.NewReplacer("\\", "\\\\", string(keySep), "\\"+string(keySep))
+
+// Joins parts in to a single key, escaping any ':' or '\' within a part so parts never run together.
+func CompositeKey(parts ...string) string {
+	escaped := make([]string, len(parts))
+	for i, p := range parts {
+		escaped[i] = keyPartEscaper.Replace(p)
+	}
+	return strings.Join(escaped, string(keySep))
+}
+
+// Splits a key built by CompositeKey back in to its parts.
+func SplitKey(key string) (parts []string) {
+	var (
+		part    []byte
+		escaped bool
+	)
+	for i := 0; i < len(key); i++ {
+		switch {
+		case escaped:
+			part = append(part, key[i])
+			escaped = false
+		case key[i] == '\\':
+			escaped = true
+		case key[i] == keySep:
+			parts = append(parts, string(part))
+			part = part[:0]
+		default:
+			part = append(part, key[i])
+		}
+	}
+	return append(parts, string(part))
+}
+
+// List keys in table built by CompositeKey whose leading parts are prefixParts, ordered by key.
+// With no prefixParts all keys in table are listed.
+func (s *Store) ListByKeyParts(table string, prefixParts ...string) (keyList []string, err error) {
+	if len(prefixParts) == 0 {
+		return s.listKeys(context.Background(), table, _sort)
+	}
+
+	return s.ListKeysLiteral(table, CompositeKey(prefixParts...)+string(keySep))
+}
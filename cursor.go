@@ -0,0 +1,357 @@
+package kvlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Cursor iterates over the keys and values of a table one row at a time, in key order.
+// Rows are read in pages and no connection is held between pages, so writers are not blocked while the Cursor is open.
+type Cursor struct {
+	store *Store
+	table string
+	page  []KV
+	pos   int
+	last  string
+	first bool
+	more  bool
+	err   error
+}
+
+// Number of rows read per query by a Cursor.
+const cursorPage = 256
+
+// Opens a Cursor over all keys in table specified, Cursor must be closed when finished.
+func (s *Store) Iterate(table string) (*Cursor, error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err := s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cursor{store: s, table: table, first: true, more: true}, nil
+}
+
+// Advances the Cursor to the next row, returns false when no rows remain or an error occured.
+func (c *Cursor) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	for c.pos+1 >= len(c.page) {
+		if !c.more {
+			c.page = nil
+			return false
+		}
+		c.page, c.last, c.more, c.err = c.store.batchPage(c.table, c.last, c.first, cursorPage)
+		if c.err != nil {
+			c.page = nil
+			return false
+		}
+		c.pos, c.first = -1, false
+	}
+
+	c.pos++
+	return true
+}
+
+// Returns key of the current row.
+func (c *Cursor) Key() string {
+	if c.pos >= len(c.page) {
+		return NONE
+	}
+	return c.page[c.pos].Key
+}
+
+// Decodes value of the current row in to output.
+func (c *Cursor) Decode(output interface{}) error {
+	if c.pos >= len(c.page) {
+		return fmt.Errorf("kvlite: Cursor has no current row.")
+	}
+	return c.page[c.pos].Decode(output)
+}
+
+// Returns the error, if any, encountered during iteration.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Closes the Cursor, ending the iteration.
+func (c *Cursor) Close() error {
+	c.page, c.more = nil, false
+	return nil
+}
+
+// WalkOption changes how Walk and Filter handle the values they visit.
+type WalkOption int
+
+const (
+	// Passes over keys whose value fails to decode with *ErrDecode, instead of returning the error.
+	// Walk continues if fn returns an *ErrDecode, Filter leaves out keys for which decode returned one.
+	SkipDecodeErrors WalkOption = 1 << iota
+)
+
+// Returns true if opts include opt.
+func hasOpt(opts []WalkOption, opt WalkOption) bool {
+	for _, o := range opts {
+		if o&opt != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if err is, or wraps, an *ErrDecode.
+func isDecodeErr(err error) bool {
+	var e *ErrDecode
+	return errors.As(err, &e)
+}
+
+// Returns keys in table for which fn returns true, fn may decode the value of key in to its own type with decode.
+// No rows are held open while fn runs, so fn may write to the Store.
+func (s *Store) Filter(table string, fn func(key string, decode func(interface{}) error) bool, opts ...WalkOption) (keyList []string, err error) {
+	skip := hasOpt(opts, SkipDecodeErrors)
+
+	err = s.visit(table, func(key string, data []byte, eFlag int) error {
+		var bad bool
+		decode := func(output interface{}) error {
+			err := s.decode(table, key, data, eFlag, output)
+			if isDecodeErr(err) {
+				bad = true
+			}
+			return err
+		}
+		if fn(key, decode) && !(skip && bad) {
+			keyList = append(keyList, key)
+		}
+		return nil
+	})
+	return keyList, err
+}
+
+// Calls fn for every key in every table, fn may decode the value of key in to its own type with decode.
+// Walk stops and returns the error if fn returns an error, no rows are held open while fn runs, so fn may write to the Store.
+func (s *Store) Walk(fn func(table, key string, decode func(interface{}) error) error, opts ...WalkOption) (err error) {
+	tables, err := s.ListTables()
+	if err != nil {
+		return err
+	}
+
+	skip := hasOpt(opts, SkipDecodeErrors)
+
+	for _, table := range tables {
+		err = s.visit(table, func(key string, data []byte, eFlag int) error {
+			decode := func(output interface{}) error {
+				return s.decode(table, key, data, eFlag, output)
+			}
+			if err := fn(table, key, decode); err != nil && !(skip && isDecodeErr(err)) {
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// Calls fn with each key in table and its stored value, reading the keys up front and each value as it is reached.
+// Keys removed before they are reached are passed over.
+func (s *Store) visit(table string, fn func(key string, data []byte, eFlag int) error) (err error) {
+	keys, err := s.listKeys(context.Background(), table, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		s.mutex.RLock()
+		data, eFlag, found, err := s.fetch(context.Background(), s.dbCon, table, key)
+		s.mutex.RUnlock()
+
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		if err = fn(key, data, eFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Number of keys read per query by KeysChan.
+const keysChanPage = 256
+
+// Streams keys in table matching filter in key order, an empty filter matches all keys.
+// Keys are read in pages and no lock or read transaction is held while waiting on the receiver, so writers are not blocked.
+// The error channel receives any error once the key channel is closed, the key channel must be drained, see KeysChanContext to stop early.
+func (s *Store) KeysChan(table, filter string) (<-chan string, <-chan error) {
+	return s.KeysChanContext(context.Background(), table, filter)
+}
+
+// Streams keys as KeysChan does until ctx is cancelled, after which the key channel is closed and the error channel receives ctx's error.
+func (s *Store) KeysChanContext(ctx context.Context, table, filter string) (<-chan string, <-chan error) {
+	keys := make(chan string)
+	errs := make(chan error, 1)
+
+	if filter == NONE {
+		filter = "%"
+	}
+
+	go func() {
+		defer close(errs)
+		defer close(keys)
+
+		var (
+			last  string
+			first = true
+		)
+
+		for {
+			page, err := s.keysPage(ctx, table, filter, last, first)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, key := range page {
+				select {
+				case keys <- key:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+			if len(page) < keysChanPage {
+				return
+			}
+			last, first = page[len(page)-1], false
+		}
+	}()
+
+	return keys, errs
+}
+
+// Returns the next page of keys in table matching filter that sort after last.
+func (s *Store) keysPage(ctx context.Context, table, filter, last string, first bool) (page []string, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.dbCon.QueryContext(ctx, "SELECT key FROM '"+table+"' WHERE key like ? AND "+s.unexpired(table)+" AND (? OR key COLLATE "+s.collate()+" > ?)"+s.orderBy(_sort)+" LIMIT ?;", filter, time.Now().Unix(), first, last, keysChanPage)
+
+	// Prevent table does not exist errors.
+	if err != nil {
+		if isNoTable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		page = append(page, key)
+	}
+	return page, rows.Err()
+}
+
+// KV is a key and its value as passed to the fn of Batch.
+type KV struct {
+	Key    string                         // Key of the value.
+	Decode func(output interface{}) error // Decodes the value in to output.
+}
+
+// Calls fn with the keys and values of table in key order, size at a time, the last batch may be smaller.
+// No lock or read transaction is held while fn runs, so fn may write to the Store, Batch stops and returns the error if fn returns an error.
+func (s *Store) Batch(table string, size int, fn func(batch []KV) error) (err error) {
+	if size <= 0 {
+		return fmt.Errorf("kvlite: Batch size must be greater than 0.")
+	}
+
+	var (
+		batch []KV
+		last  string
+		first = true
+	)
+
+	for {
+		page, next, more, err := s.batchPage(table, last, first, size-len(batch))
+		if err != nil {
+			return err
+		}
+		batch = append(batch, page...)
+		if len(batch) == size || (!more && len(batch) > 0) {
+			if err = fn(batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+		if !more {
+			return nil
+		}
+		last, first = next, false
+	}
+}
+
+// Returns the unexpired keys and values among the next size keys in table that sort after last, along with the last key read.
+// more is false once the table is exhausted.
+func (s *Store) batchPage(table, last string, first bool, size int) (batch []KV, next string, more bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, NONE, false, err
+	}
+
+	rows, err := s.dbCon.Query("SELECT key, value, e, "+s.extCol(table, "expires_at")+" FROM '"+table+"' WHERE (? OR key COLLATE "+s.collate()+" > ?)"+s.orderBy(_sort)+" LIMIT ?;", first, last, size)
+
+	// Prevent table does not exist errors.
+	if err != nil {
+		if isNoTable(err) {
+			return nil, NONE, false, nil
+		}
+		return nil, NONE, false, err
+	}
+	defer rows.Close()
+
+	var n int
+
+	for rows.Next() {
+		var (
+			key     string
+			data    []byte
+			eFlag   int
+			expires int64
+		)
+		if err = rows.Scan(&key, &data, &eFlag, &expires); err != nil {
+			return nil, NONE, false, err
+		}
+		n++
+		next = key
+		if expired(expires) {
+			continue
+		}
+		batch = append(batch, KV{key, func(output interface{}) error {
+			return s.decode(table, key, data, eFlag, output)
+		}})
+	}
+	return batch, next, n == size, rows.Err()
+}
@@ -1,11 +1,14 @@
 package kvlite
 
 import (
+	"bytes"
+	"compress/zlib"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"io/ioutil"
 	"math/big"
 )
 
@@ -72,3 +75,53 @@ func decrypt(input []byte, key []byte) (decoded []byte) {
 
 	return
 }
+
+// Compresses input with zlib.
+func compress(input []byte) []byte {
+	var buff bytes.Buffer
+	w := zlib.NewWriter(&buff)
+	w.Write(input)
+	w.Close()
+	return buff.Bytes()
+}
+
+// Decompresses zlib compressed input.
+func decompress(input []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// Version byte leading blobs written by encryptGCM.
+const gcmV1 = 1
+
+// Encrypts input with AES-256-GCM using a random nonce, returning version, nonce and sealed input.
+func encryptGCM(input []byte, key []byte) []byte {
+	block, _ := aes.NewCipher(hashBytes(key))
+	gcm, _ := cipher.NewGCM(block)
+
+	out := make([]byte, 1+gcm.NonceSize(), 1+gcm.NonceSize()+len(input)+gcm.Overhead())
+	out[0] = gcmV1
+	rand.Read(out[1:])
+
+	return gcm.Seal(out, out[1:], input, nil)
+}
+
+// Decrypts input written by encryptGCM, returning ErrDecryptFailed if the key is wrong or input was altered.
+func decryptGCM(input []byte, key []byte) ([]byte, error) {
+	block, _ := aes.NewCipher(hashBytes(key))
+	gcm, _ := cipher.NewGCM(block)
+
+	if len(input) < 1+gcm.NonceSize() || input[0] != gcmV1 {
+		return nil, ErrDecryptFailed
+	}
+
+	output, err := gcm.Open(nil, input[1:1+gcm.NonceSize()], input[1+gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecryptFailed
+	}
+	return output, nil
+}
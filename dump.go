@@ -0,0 +1,292 @@
+package kvlite
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Identifies a binary dump written by DumpBinary.
+var dumpMagic = []byte("KVLB\x01")
+
+// Record types of a binary dump.
+const (
+	dumpTable = 'T' // Starts the rows of a table: name.
+	dumpRow   = 'R' // A key of the current table: key type, key, e, expires_at, updated_at, value.
+	dumpEnd   = 'E' // End of the dump.
+)
+
+// Key types of a binary dump row.
+const (
+	dumpTextKey = 0
+	dumpIntKey  = 1
+)
+
+// Writes all tables, keys and values to w in a compact length-prefixed binary format, to be restored with LoadBinary.
+// Encrypted values are written decrypted, as with Export.
+func (s *Store) DumpBinary(w io.Writer) (err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
+
+	ctx := context.Background()
+
+	tables, err := allTables(ctx, s.dbCon)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+
+	if _, err = bw.Write(dumpMagic); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if isReserved(table) {
+			continue
+		}
+		bw.WriteByte(dumpTable)
+		writeBytes(bw, []byte(table))
+		if err = s.dumpTable(ctx, bw, table); err != nil {
+			return err
+		}
+	}
+
+	bw.WriteByte(dumpEnd)
+	return bw.Flush()
+}
+
+func (s *Store) dumpTable(ctx context.Context, bw *bufio.Writer, table string) (err error) {
+	rows, err := s.dbCon.QueryContext(ctx, "SELECT key, typeof(key), value, e, "+s.extCol(table, "expires_at")+", "+s.extCol(table, "updated_at")+" FROM '"+table+"';")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var num [binary.MaxVarintLen64]byte
+
+	for rows.Next() {
+		var (
+			key     string
+			kType   string
+			data    []byte
+			eFlag   int
+			expires int64
+			updated int64
+		)
+		if err = rows.Scan(&key, &kType, &data, &eFlag, &expires, &updated); err != nil {
+			return err
+		}
+		if expired(expires) {
+			continue
+		}
+
+		// Native values are written as stored, others decrypted and decompressed.
+		if eFlag&ePrim == 0 {
+			if data, err = s.unseal(data, eFlag); err != nil {
+				return err
+			}
+		}
+
+		bw.WriteByte(dumpRow)
+		if kType == "integer" {
+			bw.WriteByte(dumpIntKey)
+		} else {
+			bw.WriteByte(dumpTextKey)
+		}
+		writeBytes(bw, []byte(key))
+		bw.Write(num[:binary.PutUvarint(num[:], uint64(eFlag))])
+		bw.Write(num[:binary.PutVarint(num[:], expires)])
+		bw.Write(num[:binary.PutVarint(num[:], updated)])
+		if err = writeBytes(bw, data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Writes b to bw preceded by its length.
+func writeBytes(bw *bufio.Writer, b []byte) (err error) {
+	var num [binary.MaxVarintLen64]byte
+	if _, err = bw.Write(num[:binary.PutUvarint(num[:], uint64(len(b)))]); err != nil {
+		return err
+	}
+	_, err = bw.Write(b)
+	return err
+}
+
+// Reads a length-prefixed byte slice from br.
+func readBytes(br *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(br, b)
+	return b, err
+}
+
+// Creates a new Store at filePath opened with padlock from a dump written by DumpBinary, encrypted values are encrypted with the new Store's key.
+// All keys are inserted within a single transaction, on failure the new database is removed.
+func LoadBinary(filePath string, r io.Reader, padlock ...[]byte) (_ *Store, err error) {
+	if _, err = os.Stat(filePath); err == nil {
+		return nil, fmt.Errorf("kvlite: %s already exists.", filePath)
+	}
+
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(dumpMagic))
+	if _, err = io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, dumpMagic) {
+		return nil, fmt.Errorf("kvlite: Unsupported binary dump format.")
+	}
+
+	s, err := Open(filePath, padlock...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			s.Close()
+			os.Remove(filePath)
+		}
+	}()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err = s.loadBinary(br); err != nil {
+		return nil, fmt.Errorf("kvlite: Unable to load binary dump: %w", err)
+	}
+	return s, nil
+}
+
+func (s *Store) loadBinary(br *bufio.Reader) (err error) {
+	tx, err := s.dbCon.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var (
+		rec     byte
+		table   string
+		created bool
+		ins     *sql.Stmt
+	)
+
+	// Creates the current table, with an integer key if intKey is set.
+	create := func(intKey bool) (err error) {
+		var k interface{} = NONE
+		if intKey {
+			k = 0
+		}
+		if _, err = tx.Exec("CREATE TABLE '" + table + "' (" + tableDef(k) + ");"); err != nil {
+			return err
+		}
+		if ins != nil {
+			ins.Close()
+		}
+		ins, err = tx.Prepare("INSERT INTO '" + table + "'(key,value,e,expires_at,updated_at,version) VALUES(?, ?, ?, ?, ?, 1);")
+		created = true
+		return err
+	}
+	defer func() {
+		if ins != nil {
+			ins.Close()
+		}
+	}()
+
+	for {
+		if rec, err = br.ReadByte(); err != nil {
+			return err
+		}
+
+		// Tables without keys are created with a text key once the next record shows no rows follow.
+		if (rec == dumpEnd || rec == dumpTable) && table != NONE && !created {
+			if err = create(false); err != nil {
+				return err
+			}
+		}
+
+		switch rec {
+		case dumpEnd:
+			return tx.Commit()
+		case dumpTable:
+			name, err := readBytes(br)
+			if err != nil {
+				return err
+			}
+			table, created = string(name), false
+			if err = s.chkTable(&table, 0); err != nil {
+				return err
+			}
+		case dumpRow:
+			if table == NONE {
+				return fmt.Errorf("row outside of table")
+			}
+			kType, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			key, err := readBytes(br)
+			if err != nil {
+				return err
+			}
+			eFlag, err := binary.ReadUvarint(br)
+			if err != nil {
+				return err
+			}
+			expires, err := binary.ReadVarint(br)
+			if err != nil {
+				return err
+			}
+			updated, err := binary.ReadVarint(br)
+			if err != nil {
+				return err
+			}
+			data, err := readBytes(br)
+			if err != nil {
+				return err
+			}
+
+			// The first key of a table decides its key type.
+			if !created {
+				if err = create(kType == dumpIntKey); err != nil {
+					return err
+				}
+			}
+
+			var value interface{}
+			if int(eFlag)&ePrim != 0 {
+				if value, err = toNative(data, int(eFlag)); err != nil {
+					return err
+				}
+			} else {
+				value = s.seal(data, int(eFlag))
+			}
+
+			if _, err = ins.Exec(string(key), value, int(eFlag), expires, updated); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown record type %q", rec)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package kvlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Versions of the format written by Export, version 2 holds values sealed by ExportEncrypted and is only written by it.
+const (
+	exportVersion       = 1
+	exportSealedVersion = 2
+)
+
+// First line of an export, identifies the format.
+type exportHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// A single key of an export, Value holds the decrypted and decompressed encoding of the value, encrypted with AES-256-GCM if Sealed.
+type exportRecord struct {
+	Table   string `json:"table"`
+	Key     string `json:"key"`
+	IntKey  bool   `json:"int_key,omitempty"`
+	E       int    `json:"e"`
+	Expires int64  `json:"expires_at,omitempty"`
+	Value   []byte `json:"value"`
+	Sealed  bool   `json:"sealed,omitempty"`
+}
+
+// Writes all tables, keys and values to w as JSON lines, encrypted values are written decrypted.
+func (s *Store) Export(w io.Writer) (err error) {
+	return s.export(w, nil)
+}
+
+// Writes all tables, keys and values to w as Export does, encrypting values that are encrypted in the Store with key rather than writing them decrypted.
+// Read the export back with ImportEncrypted and the same key.
+func (s *Store) ExportEncrypted(w io.Writer, key []byte) (err error) {
+	if len(key) == 0 {
+		return ErrNoKey
+	}
+	return s.export(w, key)
+}
+
+// Writes the export, sealing encrypted values with key if set.
+func (s *Store) export(w io.Writer, key []byte) (err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
+
+	ctx := context.Background()
+
+	tables, err := allTables(ctx, s.dbCon)
+	if err != nil {
+		return err
+	}
+
+	version := exportVersion
+	if key != nil {
+		version = exportSealedVersion
+	}
+
+	enc := json.NewEncoder(w)
+	if err = enc.Encode(exportHeader{"kvlite", version}); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if isReserved(table) {
+			continue
+		}
+		if err = s.exportTable(ctx, enc, table, key); err != nil {
+			return err
+		}
+	}
+	return
+}
+
+func (s *Store) exportTable(ctx context.Context, enc *json.Encoder, table string, key []byte) (err error) {
+	rows, err := s.dbCon.QueryContext(ctx, "SELECT key, typeof(key), value, e, "+s.extCol(table, "expires_at")+" FROM '"+table+"';")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			rec   exportRecord
+			kType string
+			data  []byte
+		)
+		if err = rows.Scan(&rec.Key, &kType, &data, &rec.E, &rec.Expires); err != nil {
+			return err
+		}
+		if expired(rec.Expires) {
+			continue
+		}
+		if rec.Value, err = s.unseal(data, rec.E); err != nil {
+			return err
+		}
+		if key != nil && rec.E&eCrypt != 0 {
+			rec.Value, rec.Sealed = encryptGCM(rec.Value, key), true
+		}
+		rec.Table = table
+		rec.E = rec.E &^ ePrim
+		rec.IntKey = kType == "integer"
+		if err = enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Loads keys written by Export from r in to the Store, values flagged as encrypted are encrypted with the Store's key.
+// Exports written by ExportEncrypted fail with ErrNoKey, see ImportEncrypted.
+func (s *Store) Import(r io.Reader) (err error) {
+	return s.importFrom(r, nil)
+}
+
+// Loads keys written by ExportEncrypted from r in to the Store as Import does, decrypting sealed values with key.
+func (s *Store) ImportEncrypted(r io.Reader, key []byte) (err error) {
+	if len(key) == 0 {
+		return ErrNoKey
+	}
+	return s.importFrom(r, key)
+}
+
+// Reads an export, unsealing values with sealKey if set.
+func (s *Store) importFrom(r io.Reader, sealKey []byte) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	dec := json.NewDecoder(r)
+
+	var header exportHeader
+	if err = dec.Decode(&header); err != nil {
+		return err
+	}
+	if header.Format != "kvlite" || header.Version > exportSealedVersion {
+		return fmt.Errorf("kvlite: Unsupported export format '%s' version %d.", header.Format, header.Version)
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.dbCon.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var changes []txnChange
+
+	for {
+		var rec exportRecord
+		if err = dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		if err = s.chkTable(&rec.Table, 0); err != nil {
+			return err
+		}
+		if err = s.chkProjected(rec.Table); err != nil {
+			return err
+		}
+
+		var key interface{} = rec.Key
+		if rec.IntKey {
+			var n int
+			if _, err = fmt.Sscan(rec.Key, &n); err != nil {
+				return err
+			}
+			key = n
+		}
+
+		if rec.Sealed {
+			if sealKey == nil {
+				return ErrNoKey
+			}
+			if rec.Value, err = decryptGCM(rec.Value, sealKey); err != nil {
+				return err
+			}
+		}
+
+		rec.E = rec.E | s.defaultCrypt()
+		value := s.seal(rec.Value, rec.E)
+		if err = s.put(ctx, tx, rec.Table, key, value, rec.E, rec.Expires); err != nil {
+			return err
+		}
+		changes = append(changes, txnChange{rec.Table, key, EventSet, value, rec.E})
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		s.notifyValue(c.table, c.key, c.op, c.value, c.eFlag)
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package kvlite
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"sort"
 	"strconv"
 )
@@ -24,7 +26,7 @@ var ErrBadPadlock = errors.New("kvlite: Invalid padlock provided, unable to open
 
 // Sets a lock on Store database, requires a passphrase (for unlocking in future) and padlock when opening database in future.
 func Lock(filepath, passphrase string, padlock []byte) (err error) {
-	Stor, err := open(filepath, nil, _reserved)
+	Stor, err := open(filepath, Options{}, _reserved)
 	if err != nil {
 		return err
 	}
@@ -36,14 +38,14 @@ func Lock(filepath, passphrase string, padlock []byte) (err error) {
 	if count == xSlots+4 {
 		err = ErrNotUnlocked
 	} else {
-		Stor.dblocker(hashBytes([]byte(passphrase)), Stor.key, padlock)
+		Stor.dblocker(Stor.dbCon, hashBytes([]byte(passphrase)), Stor.key, padlock)
 	}
 	return
 }
 
 // Removes lock on Store database, strips the requirement for padlock for opening database, requires passphrase set on initial lock.
 func Unlock(filepath, passphrase string) (err error) {
-	Stor, err := open(filepath, nil, _reserved)
+	Stor, err := open(filepath, Options{}, _reserved)
 	if err != nil {
 		return err
 	}
@@ -59,9 +61,11 @@ func Unlock(filepath, passphrase string) (err error) {
 
 	var XMsg [3][]byte
 
-	Stor.Get("KVLite", "X"+strconv.Itoa(xSlots), &XMsg[0])
-	Stor.Get("KVLite", "X"+strconv.Itoa(xSlots+1), &XMsg[1])
-	Stor.Get("KVLite", "X"+strconv.Itoa(xSlots+2), &XMsg[2])
+	for i := range XMsg {
+		if XMsg[i], err = Stor.getSlot(xSlots + i); err != nil {
+			return err
+		}
+	}
 
 	vKey := Stor.unscram(XMsg[0])
 	vRKey := Stor.unscram(XMsg[1])
@@ -72,15 +76,48 @@ func Unlock(filepath, passphrase string) (err error) {
 	decrypted2 := decrypt(vKey, decrypted[0:32])
 
 	if bytes.Compare(decrypted, decrypted2) == 0 {
-		Stor.dblocker(nil, decrypted, nil)
+		Stor.dblocker(Stor.dbCon, nil, decrypted, nil)
 	} else {
 		return ErrBadPass
 	}
 	return
 }
 
+// Reports whether padlock opens the Store database at filepath, the database is opened read-only and left unchanged.
+// A database that was never locked accepts any padlock.
+func VerifyPadlock(filepath string, padlock []byte) (ok bool, err error) {
+	Stor, err := open(filepath, Options{}, _readonly|_reserved)
+	if err != nil {
+		return false, err
+	}
+	defer Stor.Close()
+
+	count, err := Stor.CountKeys("KVLite", "X%%")
+	if err != nil {
+		return false, err
+	}
+	if count < xSlots+3 {
+		return false, fmt.Errorf("kvlite: %s is not a kvlite database.", filepath)
+	}
+
+	switch err = Stor.dbunlocker(padlock); err {
+	case nil:
+		return true, nil
+	case ErrBadPadlock:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
 // Sets and randomizes keys in Store table for Store encryption key.
-func (s *Store) dblocker(passphrase, key, padlock []byte) []byte {
+func (s *Store) dblocker(db dbExec, passphrase, key, padlock []byte) []byte {
+	ctx := context.Background()
+
+	stage := func(slot int, v []byte) {
+		s.put(ctx, db, "KVLite_Staging", "X"+strconv.Itoa(slot), s.seal(v, 0), 0, 0)
+	}
+
 	// Set passphrase and/or key to random if not specified.
 	if passphrase == nil {
 		passphrase = hashBytes(randBytes(256))
@@ -146,7 +183,7 @@ func (s *Store) dblocker(passphrase, key, padlock []byte) []byte {
 	}
 
 	for i, v := range lock {
-		s.set("KVLite_Staging", "X"+strconv.Itoa(i), v, _reserved)
+		stage(i, v)
 	}
 
 	randKey := hashBytes(randBytes(256))
@@ -174,17 +211,17 @@ func (s *Store) dblocker(passphrase, key, padlock []byte) []byte {
 	encryptedKey3 = scram(encryptedKey3)
 
 	// Store verifcation message which is the key encrypted with the key.
-	s.set("KVLite_Staging", "X"+strconv.Itoa(xSlots), encryptedKey3, _reserved)
-	s.set("KVLite_Staging", "X"+strconv.Itoa(xSlots+1), encryptedKey2, _reserved)
-	s.set("KVLite_Staging", "X"+strconv.Itoa(xSlots+2), encryptedKey1, _reserved)
+	stage(xSlots, encryptedKey3)
+	stage(xSlots+1, encryptedKey2)
+	stage(xSlots+2, encryptedKey1)
 
 	if padlock != nil {
-		s.set("KVLite_Staging", "X"+strconv.Itoa(xSlots+3), randBytes(slotSize), _reserved)
+		stage(xSlots+3, randBytes(slotSize))
 	}
 
-	s.dbCon.Exec("DROP TABLE KVLite")
-	if _, err := s.dbCon.Exec("ALTER TABLE KVLite_Staging RENAME TO KVLite"); err == nil {
-		s.dbCon.Exec("DROP TABLE KVLite_Staging")
+	db.ExecContext(ctx, "DROP TABLE KVLite")
+	if _, err := db.ExecContext(ctx, "ALTER TABLE KVLite_Staging RENAME TO KVLite"); err == nil {
+		db.ExecContext(ctx, "DROP TABLE KVLite_Staging")
 	}
 	return key[0:32]
 }
@@ -197,7 +234,7 @@ func (s *Store) dbunlocker(padlock []byte) (err error) {
 	if err != nil {
 		return err
 	}
-	if c == 0 {
+	if c == 0 && !s.readOnly {
 		c, err := s.CountKeys("KVLite_Staging")
 		if err != nil {
 			return err
@@ -218,7 +255,12 @@ func (s *Store) dbunlocker(padlock []byte) (err error) {
 	count := len(slots)
 
 	if count == 0 {
-		s.key = s.dblocker(nil, nil, padlock)
+		if s.readOnly {
+			return
+		}
+		s.key = s.dblocker(s.dbCon, nil, nil, padlock)
+		s.padlock = padlock
+		s.storedKey = true
 		return
 	}
 
@@ -226,10 +268,16 @@ func (s *Store) dbunlocker(padlock []byte) (err error) {
 		padlock = nil
 	}
 
+	if count < xSlots+3 {
+		return ErrBadPadlock
+	}
+
 	XMsg := make([][]byte, count)
 
 	for i := 0; i < count; i++ {
-		s.Get("KVLite", "X"+strconv.Itoa(i), &XMsg[i])
+		if XMsg[i], err = s.getSlot(i); err != nil {
+			return err
+		}
 	}
 
 	s.mutex.Lock()
@@ -284,15 +332,32 @@ func (s *Store) dbunlocker(padlock []byte) (err error) {
 			}
 			if passphrase, key := tryPass(XMsg[a][b:b+keyLen], a); key != nil {
 				s.key = key
+				s.padlock = padlock
+				s.passphrase = passphrase
+				s.storedKey = true
 				s.mutex.Unlock()
-				s.dblocker(passphrase, key, padlock)
+				if !s.readOnly {
+					s.dblocker(s.dbCon, passphrase, key, padlock)
+				}
 				return
 			}
 		}
 	}
 
+	s.mutex.Unlock()
 	return ErrBadPadlock
+}
 
+// Reads slot i of the KVLite table, failing with ErrBadPadlock if it is missing or not a full slot.
+func (s *Store) getSlot(i int) (slot []byte, err error) {
+	found, err := s.Get("KVLite", "X"+strconv.Itoa(i), &slot)
+	if err != nil {
+		return nil, fmt.Errorf("kvlite: Unable to read encryption key: %w", err)
+	}
+	if !found || len(slot) != slotSize {
+		return nil, ErrBadPadlock
+	}
+	return slot, nil
 }
 
 func (s *Store) unscram(src []byte) (out []byte) {
@@ -2,25 +2,53 @@
 package kvlite
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/mattn/go-sqlite3"
+	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
+	"unicode"
 )
 
 type Store struct {
-	key      []byte
-	filePath string
-	mutex    sync.RWMutex
-	encoder  *json.Encoder
-	buffer   *bytes.Buffer
-	dbCon    *sql.DB
+	key            []byte
+	padlock        []byte
+	passphrase     []byte
+	storedKey      bool
+	readOnly       bool
+	caseSensitive  bool
+	encryptAll     bool
+	tableValidator func(table string) error
+	cache          *valueCache
+	closed         int32
+	filePath       string
+	mutex          sync.RWMutex
+	codec          Codec
+	cipher         Cipher
+	zipMin         int
+	maxValue       int
+	retryAttempts  int
+	retryBase      time.Duration
+	dbCon          *sql.DB
+	ownsDB         bool
+	connector      *connector
+	watchMutex     sync.Mutex
+	watchers       map[*watcher]struct{}
+	subscribers    map[*subscriber]struct{}
+	pending        []pendingChange
+	dispatching    bool
+	projections    map[string][]projection
+	legacy         map[string]bool
+	stmtMutex      sync.Mutex
+	stmts          map[string]map[string]*sql.Stmt
 }
 
 const (
@@ -34,238 +62,1380 @@ const (
 	_sort
 	_revsort
 	_reserved
+	_compress
+	_memory
+	_readonly
+	_shared
+	_nomigrate
 )
 
+// ErrValueTooLarge is returned if a value larger than the limit set with SetMaxValueSize is written.
+var ErrValueTooLarge = errors.New("kvlite: Value exceeds maximum value size, unable to write.")
+
+// ErrReadOnly is returned if a write is attempted on a Store opened with OpenReadOnly.
+var ErrReadOnly = errors.New("kvlite: Store was opened read-only, unable to write.")
+
+// ErrStoreClosed is returned if a Store is used after Close.
+var ErrStoreClosed = errors.New("kvlite: Store has been closed.")
+
+// ErrInvalidTableName is returned, wrapped with the name, for table names containing characters other than those allowed.
+var ErrInvalidTableName = errors.New("kvlite: Invalid characters in table name")
+
+// ErrReservedTable is returned, wrapped with the name, for table names reserved for use by kvlite.
+var ErrReservedTable = errors.New("kvlite: Table name is reserved")
+
+// ErrNoSuchTable is returned, wrapped with the name, by methods requiring an existing table, such as RenameTable and CopyTable.
+var ErrNoSuchTable = errors.New("kvlite: No such table")
+
+// ErrNotFound is provided for callers to report a missing key, kvlite methods report missing keys through their found results.
+var ErrNotFound = errors.New("kvlite: Key not found.")
+
+// Returns true if err is SQLite reporting a missing table, or wraps ErrNoSuchTable.
+// SQLite has no result code of its own for a missing table, so SQLITE_ERROR is told apart by its message.
+func isNoTable(err error) bool {
+	var sqlErr sqlite3.Error
+	if errors.As(err, &sqlErr) {
+		return sqlErr.Code == sqlite3.ErrError && strings.HasPrefix(sqlErr.Error(), "no such table")
+	}
+	return errors.Is(err, ErrNoSuchTable)
+}
+
+// Returns true once Close has been called.
+func (s *Store) isClosed() bool {
+	return atomic.LoadInt32(&s.closed) != 0
+}
+
+// Bits of the e column.
+const (
+	eCrypt = 1 << iota // Value is encrypted.
+	eGob               // Value is encoded with GobCodec.
+	eCodec             // Value is encoded with a custom Codec.
+	eZip               // Value is compressed.
+	eGCM               // Value is encrypted with CipherGCM.
+)
+
+// Columns missing from tables created by early versions, which stored only key and value.
+var legacyColumns = [][2]string{
+	{"e", "INT DEFAULT 0"},
+}
+
+// Columns added to tables since the original key, value, e schema.
+var extColumns = [][2]string{
+	{"expires_at", "INT DEFAULT 0"},
+	{"updated_at", "INT DEFAULT 0"},
+	{"version", "INT DEFAULT 0"},
+}
+
+// Returns the column definitions for a new table with key of the type specified.
+func tableDef(key interface{}) string {
+	var def string
+
+	switch key.(type) {
+	case int:
+		def = "key INT PRIMARY KEY, value BLOB, e INT"
+	case []byte:
+		def = "key BLOB PRIMARY KEY, value BLOB, e INT"
+	default:
+		def = "key TEXT PRIMARY KEY, value BLOB, e INT"
+	}
+
+	for _, col := range extColumns {
+		def = def + ", " + col[0] + " " + col[1]
+	}
+	return def
+}
+
+// Returns the names of the columns of table.
+func tableColumns(ctx context.Context, db dbExec, table string) (existing map[string]bool, err error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA table_info('"+table+"');")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	existing = make(map[string]bool)
+
+	for rows.Next() {
+		var name string
+		dest := make([]interface{}, len(cols))
+		for i, col := range cols {
+			if col == "name" {
+				dest[i] = &name
+			} else {
+				dest[i] = new(interface{})
+			}
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		existing[name] = true
+	}
+	return existing, rows.Err()
+}
+
+// Adds any columns in legacyColumns or extColumns missing from table, tables without key and value columns are left alone.
+func (s *Store) upgradeTable(table string) (err error) {
+	existing, err := tableColumns(context.Background(), s.dbCon, table)
+	if err != nil {
+		return err
+	}
+
+	if !existing["key"] || !existing["value"] {
+		return nil
+	}
+
+	for _, col := range append(legacyColumns, extColumns...) {
+		if existing[col[0]] {
+			continue
+		}
+		if _, err = s.dbCon.Exec("ALTER TABLE '" + table + "' ADD COLUMN " + col[0] + " " + col[1] + ";"); err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// Lists all tables in the database, including reserved tables.
+func allTables(ctx context.Context, db dbExec) (tables []string, err error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table';")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// Adds any columns missing from tables created by earlier versions, this is done on open unless Options.NoMigrate is set or the Store was opened with OpenDB.
+// Every table with key and value columns is upgraded, including any not written by kvlite.
+func (s *Store) Migrate() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	return s.upgradeTables()
+}
+
+// Brings all tables in the database up to the current schema.
+func (s *Store) upgradeTables() (err error) {
+	tables, err := allTables(context.Background(), s.dbCon)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if err = s.upgradeTable(table); err != nil {
+			return err
+		}
+	}
+	s.legacy = nil
+	return
+}
+
+// Records the tables lacking any of extColumns, for Stores that skip migration to still read them.
+func (s *Store) findLegacyTables() (err error) {
+	ctx := context.Background()
+
+	tables, err := allTables(ctx, s.dbCon)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		existing, err := tableColumns(ctx, s.dbCon, table)
+		if err != nil {
+			return err
+		}
+		if !existing["key"] || !existing["value"] {
+			continue
+		}
+		for _, col := range extColumns {
+			if !existing[col[0]] {
+				if s.legacy == nil {
+					s.legacy = make(map[string]bool)
+				}
+				s.legacy[strings.ToLower(table)] = true
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the condition matching unexpired keys of table, taking the current time as its argument.
+// Tables not yet migrated have no expires_at column, and no keys that expire.
+func (s *Store) unexpired(table string) string {
+	if s.legacy[strings.ToLower(table)] {
+		return "(? IS NOT NULL)"
+	}
+	return "(expires_at = 0 OR expires_at > ?)"
+}
+
+// Returns col, one of extColumns, for a select list of table, 0 for tables not yet migrated.
+func (s *Store) extCol(table, col string) string {
+	if s.legacy[strings.ToLower(table)] {
+		return "0"
+	}
+	return col
+}
+
+// Characters other than letters and digits allowed in table names.
+const tableChars = "_-.:@/+ "
+
+// Returns true if table is reserved for kvlite's own use, table names match without regard to case as in SQLite.
+func isReserved(table string) bool {
+	return strings.Contains(strings.ToLower(table), strings.ToLower(RESERVED))
+}
+
 // Checks to see if table name is reserved or invalid.
-func chkTable(table *string, flags int) (err error) {
+// Table names are embedded in SQL, so only letters, digits and the characters of tableChars are allowed.
+func (s *Store) chkTable(table *string, flags int) (err error) {
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
+
 	for _, ch := range *table {
-		switch ch {
-		case 0x3b:
-			fallthrough
-		case 0x22:
-			fallthrough
-		case 0x27:
-			fallthrough
-		case 0x26:
-			fallthrough
-		case 0x28:
-			return fmt.Errorf("Invalid characters in table name: '%s'", *table)
+		if !unicode.IsLetter(ch) && !unicode.IsDigit(ch) && !strings.ContainsRune(tableChars, ch) {
+			return fmt.Errorf("%w: '%s'", ErrInvalidTableName, *table)
 		}
 	}
 
-	if flags&_reserved > 0 {
-		return
+	if isReserved(*table) {
+		if flags&_reserved > 0 {
+			return
+		}
+		return fmt.Errorf("%w: '%s'", ErrReservedTable, *table)
 	}
-	if strings.Contains(*table, RESERVED) {
-		return fmt.Errorf("Sorry, %s is a reserved name.", *table)
+	if s.tableValidator != nil {
+		return s.tableValidator(*table)
 	}
 	return
 }
 
+// Sets fn to be consulted, after the built-in checks, on each table name used outside of reserved tables, nil removes it.
+func (s *Store) SetTableValidator(fn func(table string) error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tableValidator = fn
+}
+
 // Stores value in Store datastore.
 func (s *Store) Set(table string, key interface{}, val interface{}) (err error) {
-	return s.set(table, key, val, 0)
+	return s.SetContext(context.Background(), table, key, val)
+}
+
+// Stores value in Store datastore, aborting if ctx is cancelled.
+func (s *Store) SetContext(ctx context.Context, table string, key interface{}, val interface{}) (err error) {
+	return s.set(ctx, table, key, val, 0, 0)
 }
 
 // Writes encrypted value to Store datastore.
 func (s *Store) CryptSet(table string, key interface{}, val interface{}) (err error) {
-	return s.set(table, key, val, _encrypt)
+	return s.set(context.Background(), table, key, val, _encrypt, 0)
 }
 
-// Internal function to write to SQLite.
-func (s *Store) set(table string, key interface{}, val interface{}, flags int) (err error) {
+// Writes compressed value to Store datastore, values smaller than the compression threshold are stored uncompressed.
+func (s *Store) CompressSet(table string, key interface{}, val interface{}) (err error) {
+	return s.set(context.Background(), table, key, val, _compress, 0)
+}
+
+// Writes compressed and encrypted value to Store datastore.
+func (s *Store) CryptCompressSet(table string, key interface{}, val interface{}) (err error) {
+	return s.set(context.Background(), table, key, val, _compress|_encrypt, 0)
+}
 
+// Sets the minimum encoded size in bytes a value must be for CompressSet to compress it, defaults to 1024.
+func (s *Store) SetCompressionThreshold(bytes int) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	s.zipMin = bytes
+}
 
-	var (
-		eFlag    int
-		encBytes []byte
-	)
+// Sets the maximum size in bytes of an encoded value after compression, larger values fail with ErrValueTooLarge, 0 is unlimited.
+func (s *Store) SetMaxValueSize(bytes int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.maxValue = bytes
+}
 
-	// Encode the data.
-	switch v := val.(type) {
-	case []byte:
-		encBytes = v
-	default:
-		s.buffer.Reset()
-		err = s.encoder.Encode(val)
-		if err != nil {
-			return err
-		}
-		encBytes = s.buffer.Bytes()
-	}
+// Internal function to write to SQLite.
+func (s *Store) set(ctx context.Context, table string, key interface{}, val interface{}, flags int, ttl time.Duration) (err error) {
 
-	err = chkTable(&table, flags)
+	value, eFlag, err := s.encodeSet(table, val, flags)
 	if err != nil {
 		return err
 	}
 
-	if flags&_encrypt != 0 {
-		encBytes = encrypt(encBytes, s.key)
-		eFlag = 1
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isClosed() {
+		return ErrStoreClosed
 	}
 
-	var new_table string
+	var expires int64
 
-	switch key.(type) {
-	case int:
-		new_table = "key INT PRIMARY KEY, value BLOB, e INT"
-	default:
-		new_table = "key TEXT PRIMARY KEY, value BLOB, e INT"
+	if ttl > 0 {
+		expires = time.Now().Add(ttl).Unix()
 	}
 
-	key_str := fmt.Sprintf("%v", key)
+	projected := len(s.projectionsOf(table)) > 0
 
-	_, err = s.dbCon.Exec("CREATE TABLE IF NOT EXISTS '" + table + "' (" + new_table + ");")
+	err = s.retry(ctx, func() error {
+		if projected {
+			return s.putProjected(ctx, table, key, val, value, eFlag, expires)
+		}
+		return s.put(ctx, s.dbCon, table, key, value, eFlag, expires)
+	})
 	if err != nil {
 		return err
 	}
+	s.notifyValue(table, key, EventSet, value, eFlag)
+	return nil
+}
+
+// dbExec is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type dbExec interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
 
-	s.dbCon.Exec("DELETE FROM '"+table+"' WHERE key COLLATE nocase = ?;", key_str)
-	if eFlag == 0 {
-		encBytes = []byte(base64.RawStdEncoding.EncodeToString(encBytes))
+// Writes encoded value at key to table, creating the table if needed.
+func (s *Store) put(ctx context.Context, db dbExec, table string, key interface{}, value interface{}, eFlag int, expires int64) (err error) {
+	key_str := fmt.Sprintf("%v", key)
+
+	s.uncache(table, key)
+
+	_, err = s.exec(ctx, db, table, "CREATE TABLE IF NOT EXISTS '"+table+"' ("+tableDef(key)+");")
+	if err != nil {
+		return err
 	}
 
-	_, err = s.dbCon.Exec("INSERT OR REPLACE INTO '"+table+"'(key,value,e) VALUES(?, ?, ?);", key_str, encBytes, eFlag)
+	_, err = s.exec(ctx, db, table, "INSERT OR REPLACE INTO '"+table+"'(key,value,e,expires_at,updated_at,version) VALUES(?, ?, ?, ?, ?, "+s.nextVersion(table)+");", key_str, value, eFlag, expires, millis(time.Now()), key_str)
 	if err != nil {
 		return err
 	}
 
-	return
+	// Remove the key as written with different case, if keys are matched without regard to case.
+	_, err = s.exec(ctx, db, table, "DELETE FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ? AND key != ?;", key_str, key_str)
+	return err
 }
 
-// Unset/remove key in table specified.
-func (s *Store) Unset(table string, key interface{}) error {
-	return s.unset(table, key, 0)
+// Returns the SQL expression for the version of a write to the key bound to it, one more than the key's current version.
+func (s *Store) nextVersion(table string) string {
+	return "COALESCE((SELECT MAX(version) FROM '" + table + "' WHERE key COLLATE " + s.collate() + " = ?), 0) + 1"
+}
+
+// Reads encoded value at key from table, expired keys are treated as missing.
+func (s *Store) fetch(ctx context.Context, db dbExec, table string, key interface{}) (data []byte, eFlag int, found bool, err error) {
+	var expires int64
+
+	err = s.queryRow(ctx, db, table, "SELECT value, e, "+s.extCol(table, "expires_at")+" FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ?;", fmt.Sprintf("%v", key)).Scan(&data, &eFlag, &expires)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, 0, false, nil
+	case err != nil:
+		if isNoTable(err) {
+			return nil, 0, false, nil
+		}
+		return nil, 0, false, err
+	case expired(expires):
+		return nil, 0, false, nil
+	}
+	return data, eFlag, true, nil
+}
+
+// Checks table and encodes val for set under a read lock, so concurrent writers encode in parallel ahead of the write itself.
+func (s *Store) encodeSet(table string, val interface{}, flags int) (value interface{}, eFlag int, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.readOnly {
+		return nil, 0, ErrReadOnly
+	}
+
+	if err = s.chkTable(&table, flags); err != nil {
+		return nil, 0, err
+	}
+
+	return s.encode(val, flags)
+}
+
+// Encodes val for storage, encrypting if requested by flags.
+// Strings, numbers and bools written unencrypted and uncompressed with the default codec are stored as native SQLite values.
+func (s *Store) encode(val interface{}, flags int) (value interface{}, eFlag int, err error) {
+	if s.encryptAll {
+		flags = flags | _encrypt
+	}
+
+	if s.codec == nil && flags&(_encrypt|_compress) == 0 {
+		if value, eFlag, ok := native(val); ok {
+			if str, ok := value.(string); ok {
+				if err = s.chkSize(len(str)); err != nil {
+					return nil, 0, err
+				}
+			}
+			return value, eFlag, nil
+		}
+	}
+
+	raw, eFlag, err := s.marshal(val)
+	if err != nil {
+		return nil, 0, err
+	}
+	if flags&_compress != 0 && len(raw) >= s.zipMin {
+		eFlag = eFlag | eZip
+	}
+	if flags&_encrypt != 0 {
+		if len(s.key) == 0 {
+			return nil, 0, ErrNoKey
+		}
+		eFlag = eFlag | s.cryptFlag()
+	}
+	sealed, err := s.sealValue(raw, eFlag)
+	if err != nil {
+		return nil, 0, err
+	}
+	return sealed, eFlag, nil
 }
 
-func (s *Store) unset(table string, key interface{}, flags int) (err error) {
+// Prepares raw bytes for storage, compressing and encrypting as set in eFlag.
+func (s *Store) seal(raw []byte, eFlag int) []byte {
+	if eFlag&eZip != 0 {
+		raw = compress(raw)
+	}
+	return s.crypt(raw, eFlag)
+}
+
+// Compresses and encrypts raw as seal does, failing with ErrValueTooLarge if the packed value exceeds the size limit.
+func (s *Store) sealValue(raw []byte, eFlag int) ([]byte, error) {
+	if eFlag&eZip != 0 {
+		raw = compress(raw)
+	}
+	if err := s.chkSize(len(raw)); err != nil {
+		return nil, err
+	}
+	return s.crypt(raw, eFlag), nil
+}
+
+// Returns ErrValueTooLarge if a value of n bytes exceeds the limit set with SetMaxValueSize.
+func (s *Store) chkSize(n int) error {
+	if s.maxValue > 0 && n > s.maxValue {
+		return ErrValueTooLarge
+	}
+	return nil
+}
+
+// Encrypts or base64 encodes packed bytes for storage as set in eFlag.
+func (s *Store) crypt(packed []byte, eFlag int) []byte {
+	if eFlag&eCrypt != 0 {
+		return encryptFlag(packed, eFlag, s.key)
+	}
+	return []byte(base64.RawStdEncoding.EncodeToString(packed))
+}
+
+// Stores all key/value pairs in pairs to table within a single transaction.
+func (s *Store) SetMany(table string, pairs map[string]interface{}) (err error) {
+	return s.SetManyContext(context.Background(), table, pairs)
+}
+
+// Stores all key/value pairs in pairs to table within a single transaction, rolling back if ctx is cancelled.
+func (s *Store) SetManyContext(ctx context.Context, table string, pairs map[string]interface{}) (err error) {
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	err = chkTable(&table, flags)
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	err = s.chkTable(&table, 0)
 	if err != nil {
 		return err
 	}
 
-	key_str := fmt.Sprintf("%v", key)
-
-	if _, err := s.dbCon.Exec("DELETE FROM '"+table+"' WHERE key COLLATE nocase = ?;", key_str); err != nil {
-		if strings.Contains(err.Error(), "no such table") == true {
-			return nil
-		}
+	tx, err := s.dbCon.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
 		if err != nil {
-			return err
+			tx.Rollback()
 		}
+	}()
+
+	_, err = tx.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS '"+table+"' ("+tableDef(NONE)+");")
+	if err != nil {
+		return err
 	}
-	return
-}
 
-// Truncates the KVLite table to reset the encryption keys for database.
-func (s *Store) CryptReset() error {
-	// Truncate KVLite table.
-	err := s.Truncate(RESERVED)
+	s.cache.forgetTables(table)
+
+	del, err := tx.PrepareContext(ctx, "DELETE FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ? AND key != ?;")
 	if err != nil {
 		return err
 	}
-	tables, err := s.ListTables()
+	defer del.Close()
+
+	ins, err := tx.PrepareContext(ctx, "INSERT OR REPLACE INTO '"+table+"'(key,value,e,updated_at,version) VALUES(?, ?, ?, ?, "+s.nextVersion(table)+");")
 	if err != nil {
 		return err
 	}
-
-	// Erase any encrypted entries.
-	for _, table := range tables {
-		if _, err := s.dbCon.Exec("DELETE FROM '"+table+"' WHERE e = ?;", 1); err != nil {
+	defer ins.Close()
+
+	changes := make([]txnChange, 0, len(pairs))
+
+	for key, val := range pairs {
+		value, eFlag, err := s.encode(val, 0)
+		if err != nil {
+			return err
+		}
+		if _, err = ins.ExecContext(ctx, key, value, eFlag, millis(time.Now()), key); err != nil {
+			return err
+		}
+		if _, err = del.ExecContext(ctx, key, key); err != nil {
+			return err
+		}
+		if err = s.project(ctx, tx, table, key, val); err != nil {
+			return err
+		}
+		changes = append(changes, txnChange{table, key, EventSet, value, eFlag})
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		s.notifyValue(c.table, c.key, c.op, c.value, c.eFlag)
+	}
+	return nil
+}
+
+// Unset/remove key in table specified.
+func (s *Store) Unset(table string, key interface{}) error {
+	return s.UnsetContext(context.Background(), table, key)
+}
+
+// Unset/remove key in table specified, aborting if ctx is cancelled.
+func (s *Store) UnsetContext(ctx context.Context, table string, key interface{}) error {
+	_, err := s.unset(ctx, table, key, 0)
+	return err
+}
+
+// Unset/remove key in table specified, returns true if key existed and was removed.
+func (s *Store) UnsetR(table string, key interface{}) (deleted bool, err error) {
+	return s.unset(context.Background(), table, key, 0)
+}
+
+func (s *Store) unset(ctx context.Context, table string, key interface{}, flags int) (deleted bool, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+
+	err = s.chkTable(&table, flags)
+	if err != nil {
+		return false, err
+	}
+
+	key_str := fmt.Sprintf("%v", key)
+
+	var result sql.Result
+
+	err = s.retry(ctx, func() (err error) {
+		result, err = s.dbCon.ExecContext(ctx, "DELETE FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ?;", key_str)
+		return err
+	})
+	s.uncache(table, key)
+	if err != nil {
+		if isNoTable(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		s.notify(table, key, EventUnset)
+	}
+	return n > 0, nil
+}
+
+// Removes all keys in table matching filter, returns the number of keys removed.
+func (s *Store) UnsetMatching(table, filter string) (deleted int, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	s.cache.forgetTables(table)
+
+	return s.unsetWhere(table, "key like ?", filter)
+}
+
+// Removes all keys in table starting with prefix, returns the number of keys removed.
+// Unlike UnsetMatching, % and _ in prefix are matched literally.
+func (s *Store) UnsetPrefix(table, prefix string) (deleted int, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	s.cache.forgetTables(table)
+
+	pattern := EscapeLike(prefix) + "%"
+	return s.unsetWhere(table, likeEscaped(pattern), pattern)
+}
+
+// Removes keys from table in a single transaction, returns the number of keys removed.
+func (s *Store) UnsetMany(table string, keys []string) (deleted int, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.dbCon.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var removed []string
+	watched := s.watched()
+
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > maxParams {
+			n = maxParams
+		}
+		chunk := keys[:n]
+		keys = keys[n:]
+
+		args := make([]interface{}, len(chunk))
+		for i, k := range chunk {
+			args[i] = k
+			s.uncache(table, k)
+		}
+
+		where := "key COLLATE " + s.collate() + " IN (?" + strings.Repeat(", ?", len(chunk)-1) + ")"
+
+		if watched {
+			found, err := selectKeys(tx, table, where, args...)
+			if err != nil && !isNoTable(err) {
+				return 0, err
+			}
+			removed = append(removed, found...)
+		}
+
+		result, err := tx.Exec("DELETE FROM '"+table+"' WHERE "+where+";", args...)
+		if err != nil {
+			if isNoTable(err) {
+				tx.Rollback()
+				return 0, nil
+			}
+			return 0, err
+		}
+		n64, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted = deleted + int(n64)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, k := range removed {
+		s.notify(table, k, EventUnset)
+	}
+	return deleted, nil
+}
+
+// Removes the keys of table matching where, telling watchers and subscribers of each key removed.
+func (s *Store) unsetWhere(table, where string, args ...interface{}) (deleted int, err error) {
+	var removed []string
+
+	if s.watched() {
+		if removed, err = selectKeys(s.dbCon, table, where, args...); err != nil {
+			if isNoTable(err) {
+				return 0, nil
+			}
+			return 0, err
+		}
+	}
+
+	result, err := s.dbCon.Exec("DELETE FROM '"+table+"' WHERE "+where+";", args...)
+	if err != nil {
+		if isNoTable(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	for _, k := range removed {
+		s.notify(table, k, EventUnset)
+	}
+	return int(n), nil
+}
+
+// Returns the keys of table matching where.
+func selectKeys(db dbExec, table, where string, args ...interface{}) (keys []string, err error) {
+	rows, err := db.QueryContext(context.Background(), "SELECT key FROM '"+table+"' WHERE "+where+";", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Escapes %, _ and backslash in str with a backslash, for LIKE patterns matched with ESCAPE '\'.
+// Key filters such as those of ListKeys are matched without ESCAPE, see ListKeysLiteral to match a prefix literally.
+func EscapeLike(str string) string {
+	return likeEscaper.Replace(str)
+}
+
+// Returns a LIKE comparison of key against a pattern escaped with EscapeLike.
+// ESCAPE is only added when pattern holds an escape, as it keeps SQLite from using an index on key.
+func likeEscaped(pattern string) string {
+	if strings.Contains(pattern, "\\") {
+		return "key like ? ESCAPE '\\'"
+	}
+	return "key like ?"
+}
+
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+// Truncates the KVLite table to reset the encryption keys for database.
+func (s *Store) CryptReset() error {
+	// Truncate KVLite table.
+	err := s.truncate(RESERVED, _reserved)
+	if err != nil {
+		return err
+	}
+	tables, err := s.ListTables()
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetTables()
+
+	// Erase any encrypted entries.
+	for _, table := range tables {
+		if _, err := s.dbCon.Exec("DELETE FROM '"+table+"' WHERE e & ? != 0;", eCrypt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Truncates a table in Store datastore, truncating a table that does not exist is a no-op.
+func (s *Store) Truncate(table string) (err error) {
+	return s.truncate(table, 0)
+}
+
+// Drops table, flags may include _reserved to allow dropping reserved tables.
+func (s *Store) truncate(table string, flags int) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	if err = s.chkTable(&table, flags); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	s.forgetStmts(table)
+	s.cache.forgetTables(table)
+
+	err = s.retry(ctx, func() (err error) {
+		_, err = s.dbCon.ExecContext(ctx, "DROP TABLE IF EXISTS '"+table+"';")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.publish(Change{Table: table, Op: EventTruncate}, nil, 0)
+	return nil
+}
+
+// Returns true if key exists in table specified, without retrieving its value.
+func (s *Store) Has(table, key string) (found bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return false, err
+	}
+
+	var one int
+
+	err = s.dbCon.QueryRow("SELECT 1 FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ? AND "+s.unexpired(table)+" LIMIT 1;", key, time.Now().Unix()).Scan(&one)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		if isNoTable(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Retrieves a value as string at key in table specified.
+func (s *Store) SGet(table string, key interface{}) (output string) {
+	s.Get(table, key, &output)
+	return output
+}
+
+// Retreive a value at key in table specified.
+// A missing key or table is not an error, found is false and err is nil.
+func (s *Store) Get(table string, key interface{}, output interface{}) (found bool, err error) {
+	return s.GetContext(context.Background(), table, key, output)
+}
+
+// Retreive a value at key in table specified, aborting if ctx is cancelled.
+func (s *Store) GetContext(ctx context.Context, table string, key interface{}, output interface{}) (found bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var eFlag int
+	var data []byte
+	var expires int64
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return false, err
+	}
+
+	key_str := fmt.Sprintf("%v", key)
+
+	if entry, ok := s.cache.get(table, s.cacheID(key)); ok && !expired(entry.expires) {
+		return true, s.decode(table, key, entry.data, entry.eFlag, output)
+	}
+
+	err = s.queryRow(ctx, s.dbCon, table, "SELECT value, e, "+s.extCol(table, "expires_at")+" FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ?;", key_str).Scan(&data, &eFlag, &expires)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		if isNoTable(err) {
+			return false, nil
+		} else {
+			return false, err
+		}
+	default:
+		if expired(expires) {
+			return false, nil
+		}
+	}
+
+	s.cacheValue(table, key, data, eFlag, expires)
+
+	return true, s.decode(table, key, data, eFlag, output)
+}
+
+// Retreive the stored bytes at key in table specified without decoding, decrypting and decompressing as needed.
+func (s *Store) GetBytes(table, key string) (value []byte, found bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, eFlag, found, err := s.fetch(context.Background(), s.dbCon, table, key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	value, err = s.unseal(data, eFlag)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Returns the size in bytes of the value at key as stored on disk, after any encoding, compression and encryption.
+func (s *Store) ValueSize(table, key string) (size int, found bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return 0, false, err
+	}
+
+	err = s.dbCon.QueryRow("SELECT LENGTH(CAST(value AS BLOB)) FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ? AND "+s.unexpired(table)+";", key, time.Now().Unix()).Scan(&size)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, false, nil
+	case err != nil:
+		if isNoTable(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return size, true, nil
+}
+
+// Returns the size in bytes of each value in table as stored on disk, only of keys matching filter if specified.
+func (s *Store) KeySizes(table, filter string) (sizes map[string]int, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter == NONE {
+		filter = "%"
+	}
+
+	sizes = make(map[string]int)
+
+	rows, err := s.dbCon.Query("SELECT key, LENGTH(CAST(value AS BLOB)) FROM '"+table+"' WHERE key like ? AND "+s.unexpired(table)+";", filter, time.Now().Unix())
+	if err != nil {
+		if isNoTable(err) {
+			return sizes, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key  string
+			size int
+		)
+		if err = rows.Scan(&key, &size); err != nil {
+			return nil, err
+		}
+		sizes[key] = size
+	}
+	return sizes, rows.Err()
+}
+
+// Maximum number of bound parameters SQLite allows in a single statement.
+const maxParams = 999
+
+// Retreive values at keys in table specified, decoding each in to the pointer returned by fn, returns which keys were found.
+func (s *Store) GetMany(table string, keys []string, fn func(key string) interface{}) (found map[string]bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	found = make(map[string]bool)
+
+	// Keys may match without regard to case, so map folded keys back to those requested.
+	requested := make(map[string]string)
+	for _, k := range keys {
+		requested[s.foldKey(k)] = k
+		found[k] = false
+	}
+
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > maxParams {
+			n = maxParams
+		}
+		chunk := keys[:n]
+		keys = keys[n:]
+
+		args := make([]interface{}, len(chunk))
+		for i, k := range chunk {
+			args[i] = k
+		}
+
+		rows, err := s.dbCon.Query("SELECT key, value, e, "+s.extCol(table, "expires_at")+" FROM '"+table+"' WHERE key COLLATE "+s.collate()+" IN (?"+strings.Repeat(", ?", len(chunk)-1)+");", args...)
+		if err != nil {
+			if isNoTable(err) {
+				return found, nil
+			}
+			return nil, err
+		}
+
+		for rows.Next() {
+			var (
+				key     string
+				data    []byte
+				eFlag   int
+				expires int64
+			)
+			if err = rows.Scan(&key, &data, &eFlag, &expires); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if expired(expires) {
+				continue
+			}
+			k, ok := requested[s.foldKey(key)]
+			if !ok {
+				continue
+			}
+			if err = s.decode(table, k, data, eFlag, fn(k)); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			found[k] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// Retreive the value at key from each of tables, decoding each in to the pointer returned by fn, returns which tables had key.
+func (s *Store) GetAcrossTables(tables []string, key string, fn func(table string) interface{}) (found map[string]bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	ctx := context.Background()
+
+	found = make(map[string]bool)
+
+	for _, table := range tables {
+		if err = s.chkTable(&table, _reserved); err != nil {
+			return nil, err
+		}
+
+		data, eFlag, ok, err := s.fetch(ctx, s.dbCon, table, key)
+		if err != nil {
+			return nil, err
+		}
+		found[table] = ok
+		if !ok {
+			continue
+		}
+
+		if err = s.decode(table, key, data, eFlag, fn(table)); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+// Retreive values of all keys in table matching filter, decoding each in to the pointer returned by fn, returns the keys found.
+func (s *Store) GetMatching(table, filter string, fn func(key string) interface{}) (found map[string]bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	found = make(map[string]bool)
+
+	rows, err := s.dbCon.Query("SELECT key, value, e FROM '"+table+"' WHERE key like ? AND "+s.unexpired(table)+";", filter, time.Now().Unix())
+
+	// Prevent table does not exist errors.
+	if err != nil {
+		if isNoTable(err) {
+			return found, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key   string
+			data  []byte
+			eFlag int
+		)
+		if err = rows.Scan(&key, &data, &eFlag); err != nil {
+			return nil, err
+		}
+		if err = s.decode(table, key, data, eFlag, fn(key)); err != nil {
+			return nil, err
+		}
+		found[key] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// Decodes stored data in to output, decrypting if eFlag is set.
+func (s *Store) decode(table string, key interface{}, data []byte, eFlag int, output interface{}) error {
+	raw, err := s.unseal(data, eFlag)
+	if err != nil {
+		return err
+	}
+	if err = s.unmarshal(raw, eFlag, output); err != nil && err != ErrNoCodec {
+		return &ErrDecode{table, fmt.Sprintf("%v", key), reflect.TypeOf(output), err}
+	}
+	return err
+}
+
+// Reverses seal, returning the raw bytes of stored data.
+func (s *Store) unseal(data []byte, eFlag int) ([]byte, error) {
+	if eFlag&ePrim != 0 {
+		return fromNative(data, eFlag)
+	}
+	if eFlag&eCrypt != 0 {
+		if len(s.key) == 0 {
+			return nil, ErrNoKey
+		}
+		var err error
+		if data, err = decryptFlag(data, eFlag, s.key); err != nil {
+			return nil, err
+		}
+	} else {
+		data, _ = base64.RawStdEncoding.DecodeString(string(data))
+	}
+	if eFlag&eZip != 0 {
+		return decompress(data)
+	}
+	return data, nil
+}
+
+// Retrieves all values in table specified, populating out with each key/value pair.
+func (s *Store) GetAll(table string, out map[string]interface{}) (err error) {
+	vals := make(map[string]*interface{})
+	err = s.GetAllFunc(table, func(key string) interface{} {
+		vals[key] = new(interface{})
+		return vals[key]
+	})
+	if err != nil {
+		return err
+	}
+	for k, v := range vals {
+		out[k] = *v
+	}
+	return
+}
+
+// Retrieves all values in table specified, decoding each value in to the pointer returned by fn.
+func (s *Store) GetAllFunc(table string, fn func(key string) interface{}) (err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.dbCon.Query("SELECT key, value, e FROM '"+table+"' WHERE "+s.unexpired(table)+";", time.Now().Unix())
+
+	// Prevent table does not exist errors.
+	if err != nil {
+		if isNoTable(err) {
+			return nil
+		} else {
+			return err
+		}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key   string
+			data  []byte
+			eFlag int
+		)
+		err = rows.Scan(&key, &data, &eFlag)
+		if err != nil {
+			return err
+		}
+		if err = s.decode(table, key, data, eFlag, fn(key)); err != nil {
 			return err
 		}
 	}
-	return nil
+	return rows.Err()
 }
 
-// Truncates a table in Store datastore.
-func (s *Store) Truncate(table string) (err error) {
+// Uses VACUUM command to shrink sqlite database.
+func (s *Store) Shrink() (err error) {
+	return s.Vacuum()
+}
+
+// Rebuilds the database with VACUUM to reclaim space left by removed keys.
+// VACUUM cannot run within a transaction, so Vacuum waits for any open Txn and blocks all other use of the Store until finished.
+func (s *Store) Vacuum() (err error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if _, err = s.dbCon.Exec("DROP TABLE '" + table + "';"); err != nil {
-		if strings.Contains(err.Error(), "no such table") == true {
-			return nil
-		}
+	if s.isClosed() {
+		return ErrStoreClosed
 	}
-	return
-}
-
-// Retrieves a value as string at key in table specified.
-func (s *Store) SGet(table string, key interface{}) (output string) {
-	s.Get(table, key, &output)
-	return output
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	_, err = s.dbCon.Exec("VACUUM;")
+	return err
 }
 
-// Retreive a value at key in table specified.
-func (s *Store) Get(table string, key interface{}, output interface{}) (found bool, err error) {
-
+// Returns the size of the database in bytes.
+func (s *Store) Size() (size int64, err error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	var eFlag int
-	var data []byte
+	if s.isClosed() {
+		return 0, ErrStoreClosed
+	}
 
-	err = chkTable(&table, _reserved)
-	if err != nil {
-		return false, err
+	var pages, pageSize int64
+
+	if err = s.dbCon.QueryRow("PRAGMA page_count;").Scan(&pages); err != nil {
+		return 0, err
+	}
+	if err = s.dbCon.QueryRow("PRAGMA page_size;").Scan(&pageSize); err != nil {
+		return 0, err
 	}
+	return pages * pageSize, nil
+}
 
-	key_str := fmt.Sprintf("%v", key)
+// Verifies the database is still reachable and, for Stores opened on a file, that the file still exists.
+func (s *Store) Ping() error {
+	return s.PingContext(context.Background())
+}
 
-	err = s.dbCon.QueryRow("SELECT value FROM '"+table+"' WHERE key COLLATE nocase = ?", key_str).Scan(&data)
+// Ping with a context to bound how long the check may take.
+func (s *Store) PingContext(ctx context.Context) error {
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
 
-	switch {
-	case err == sql.ErrNoRows:
-		return false, nil
-	case err != nil:
-		if strings.Contains(err.Error(), "no such table") == true {
-			return false, nil
-		} else {
-			return false, err
-		}
-	default:
-		err = s.dbCon.QueryRow("SELECT e FROM '"+table+"' WHERE key COLLATE nocase = ?;", key_str).Scan(&eFlag)
-		if err != nil {
-			return false, err
-		}
+	if err := s.dbCon.PingContext(ctx); err != nil {
+		return err
+	}
 
-		if eFlag != 0 {
-			data = decrypt(data, s.key)
-		} else {
-			data, _ = base64.RawStdEncoding.DecodeString(string(data))
+	// An open connection keeps working on a file deleted out from under it, so check the file itself.
+	if s.filePath != NONE && s.filePath != ":memory:" && !strings.HasPrefix(s.filePath, "file:") {
+		if _, err := os.Stat(s.filePath); err != nil {
+			return fmt.Errorf("kvlite: Unable to access %s: %w", s.filePath, err)
 		}
 	}
+	return nil
+}
 
-	switch o := output.(type) {
-	case *[]byte:
-		*o = append(*o, data[0:]...)
-	default:
-		if output == nil {
-			return true, nil
-		}
-		var dec *json.Decoder
-		dec = json.NewDecoder(bytes.NewReader(data))
-		if dec != nil {
-			return true, dec.Decode(output)
-		}
+// Runs PRAGMA integrity_check, returning true if the database is healthy, otherwise the problems found.
+func (s *Store) IntegrityCheck() (ok bool, problems []string, err error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.isClosed() {
+		return false, nil, ErrStoreClosed
 	}
 
-	return true, nil
-}
+	rows, err := s.dbCon.Query("PRAGMA integrity_check;")
+	if err != nil {
+		return false, nil, err
+	}
+	defer rows.Close()
 
-// Uses VACUUM command to shrink sqlite database.
-func (s *Store) Shrink() (err error) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	_, err = s.dbCon.Exec("VACUUM;")
-	return err
+	for rows.Next() {
+		var msg string
+		if err = rows.Scan(&msg); err != nil {
+			return false, nil, err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return false, nil, err
+	}
+	return len(problems) == 0, problems, nil
 }
 
 // List all tables, if filter specified only tables that match filter.
@@ -274,6 +1444,9 @@ func (s *Store) ListTables(filters ...string) (cList []string, err error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
+	if s.isClosed() {
+		return nil, ErrStoreClosed
+	}
 	if len(filters) == 0 {
 		filters = append(filters, NONE)
 	}
@@ -304,7 +1477,7 @@ func (s *Store) ListTables(filters ...string) (cList []string, err error) {
 				return nil, err
 			}
 
-			if !strings.Contains(table, RESERVED) {
+			if !isReserved(table) {
 				cList = append(cList, table)
 			}
 		}
@@ -330,23 +1503,25 @@ func (s *Store) CountKeys(table string, filters ...string) (count uint32, err er
 		filters = append(filters, NONE)
 	}
 
+	now := time.Now().Unix()
+
 	for _, filter := range filters {
 		var rows *sql.Rows
 
-		err = chkTable(&table, _reserved)
+		err = s.chkTable(&table, _reserved)
 		if err != nil {
 			return 0, err
 		}
 
 		if filter != NONE {
-			rows, err = s.dbCon.Query("SELECT COUNT(key) FROM '"+table+"' where key like ?;", filter)
+			rows, err = s.dbCon.Query("SELECT COUNT(key) FROM '"+table+"' where key like ? AND "+s.unexpired(table)+";", filter, now)
 		} else {
-			rows, err = s.dbCon.Query("SELECT COUNT(key) FROM '" + table + "';")
+			rows, err = s.dbCon.Query("SELECT COUNT(key) FROM '"+table+"' WHERE "+s.unexpired(table)+";", now)
 		}
 
 		// Prevent table does not exist errors.
 		if err != nil {
-			if strings.Contains(err.Error(), "no such table") == true {
+			if isNoTable(err) {
 				return 0, nil
 			} else {
 				return 0, err
@@ -384,8 +1559,68 @@ func (s *Store) ListNKeys(table string, filters ...string) (keyList []int, err e
 	return
 }
 
+// List keys in table starting with exactPrefix ordered by key, % and _ in exactPrefix are matched literally rather than as wildcards.
+func (s *Store) ListKeysLiteral(table, exactPrefix string) (keyList []string, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := EscapeLike(exactPrefix) + "%"
+
+	rows, err := s.dbCon.Query("SELECT key FROM '"+table+"' WHERE "+likeEscaped(pattern)+" AND "+s.unexpired(table)+s.orderBy(_sort)+";", pattern, time.Now().Unix())
+	if err != nil {
+		if isNoTable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keyList = append(keyList, key)
+	}
+	return keyList, rows.Err()
+}
+
 // List all keys in table, only those matching filter if specified.
 func (s *Store) ListKeys(table string, filters ...string) (keyList []string, err error) {
+	return s.ListKeysContext(context.Background(), table, filters...)
+}
+
+// List all keys in table, only those matching filter if specified, aborting if ctx is cancelled.
+func (s *Store) ListKeysContext(ctx context.Context, table string, filters ...string) (keyList []string, err error) {
+	return s.listKeys(ctx, table, 0, filters...)
+}
+
+// List all keys in table sorted by key, descending if specified, only those matching filter if specified.
+func (s *Store) ListKeysSorted(table, filter string, descending bool) (keyList []string, err error) {
+	if descending {
+		return s.listKeys(context.Background(), table, _revsort, filter)
+	}
+	return s.listKeys(context.Background(), table, _sort, filter)
+}
+
+// Returns ORDER BY clause for the _sort and _revsort flags.
+func (s *Store) orderBy(flags int) string {
+	switch {
+	case flags&_revsort != 0:
+		return " ORDER BY key COLLATE " + s.collate() + " DESC"
+	case flags&_sort != 0:
+		return " ORDER BY key COLLATE " + s.collate() + " ASC"
+	}
+	return NONE
+}
+
+func (s *Store) listKeys(ctx context.Context, table string, flags int, filters ...string) (keyList []string, err error) {
 
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -394,23 +1629,25 @@ func (s *Store) ListKeys(table string, filters ...string) (keyList []string, err
 		filters = append(filters, NONE)
 	}
 
+	now := time.Now().Unix()
+
 	for _, filter := range filters {
 		var rows *sql.Rows
 
-		err = chkTable(&table, _reserved)
+		err = s.chkTable(&table, _reserved)
 		if err != nil {
 			return nil, err
 		}
 
 		if filter != NONE {
-			rows, err = s.dbCon.Query("SELECT key FROM '"+table+"' where key like ?;", filter)
+			rows, err = s.dbCon.QueryContext(ctx, "SELECT key FROM '"+table+"' where key like ? AND "+s.unexpired(table)+s.orderBy(flags)+";", filter, now)
 		} else {
-			rows, err = s.dbCon.Query("SELECT key FROM '" + table + "';")
+			rows, err = s.dbCon.QueryContext(ctx, "SELECT key FROM '"+table+"' WHERE "+s.unexpired(table)+s.orderBy(flags)+";", now)
 		}
 
 		// Prevent table does not exist errors.
 		if err != nil {
-			if strings.Contains(err.Error(), "no such table") == true {
+			if isNoTable(err) {
 				return nil, nil
 			} else {
 				return nil, err
@@ -436,18 +1673,199 @@ func (s *Store) ListKeys(table string, filters ...string) (keyList []string, err
 	return
 }
 
-// Close Store.
+// List keys in table in the order they were written, oldest first, for replaying writes in sequence.
+// Writing an existing key with Set or its variants moves it to the end, in place updates such as SetIfPresent and RenameKey keep its position.
+func (s *Store) ListKeysByInsertion(table string) (keyList []string, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each write inserts a new row, so rowid follows the order of writes.
+	rows, err := s.dbCon.Query("SELECT key FROM '"+table+"' WHERE "+s.unexpired(table)+" ORDER BY rowid;", time.Now().Unix())
+	if err != nil {
+		if isNoTable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keyList = append(keyList, key)
+	}
+	return keyList, rows.Err()
+}
+
+// List keys in table ordered by key, returning at most limit keys starting at offset, a limit of 0 returns all keys.
+func (s *Store) ListKeysPaged(table, filter string, limit, offset int) (keyList []string, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = -1
+	}
+
+	var rows *sql.Rows
+
+	if filter != NONE {
+		rows, err = s.dbCon.Query("SELECT key FROM '"+table+"' where key like ? AND "+s.unexpired(table)+s.orderBy(_sort)+" LIMIT ? OFFSET ?;", filter, time.Now().Unix(), limit, offset)
+	} else {
+		rows, err = s.dbCon.Query("SELECT key FROM '"+table+"' WHERE "+s.unexpired(table)+s.orderBy(_sort)+" LIMIT ? OFFSET ?;", time.Now().Unix(), limit, offset)
+	}
+
+	// Prevent table does not exist errors.
+	if err != nil {
+		if isNoTable(err) {
+			return nil, nil
+		} else {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+		keyList = append(keyList, key)
+	}
+	return keyList, rows.Err()
+}
+
+// Close Store, may be called more than once and from multiple goroutines, later calls return nil.
+// Methods called on the Store after Close return ErrStoreClosed.
 func (s *Store) Close() error {
+	if !atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		return nil
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	s.forgetAllStmts()
+	if !s.ownsDB {
+		return nil
+	}
 	return s.dbCon.Close()
 }
 
+// Returns the path the Store was opened with, empty for Stores opened with OpenDB.
+func (s *Store) Path() string {
+	return s.filePath
+}
+
 // Manually override encryption key used with CryptSet.
 func (s *Store) CryptKey(key []byte) {
 	s.key = key
 }
 
+// Re-encrypts all encrypted values with newKey and makes it the encryption key of the Store, on failure the old key remains.
+func (s *Store) RotateKey(newKey []byte) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	// Keys kept in the KVLite table must be 32 bytes.
+	if s.storedKey {
+		newKey = hashBytes(newKey)
+	}
+
+	tx, err := s.dbCon.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	ctx := context.Background()
+
+	tables, err := allTables(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		id    int64
+		data  []byte
+		eFlag int
+	}
+
+	for _, table := range tables {
+		if isReserved(table) {
+			continue
+		}
+
+		rows, err := tx.Query("SELECT rowid, value, e FROM '"+table+"' WHERE e & ? != 0;", eCrypt)
+		if err != nil {
+			return err
+		}
+
+		var crypted []row
+
+		for rows.Next() {
+			var r row
+			if err = rows.Scan(&r.id, &r.data, &r.eFlag); err != nil {
+				rows.Close()
+				return err
+			}
+			crypted = append(crypted, r)
+		}
+		if err = rows.Err(); err != nil {
+			rows.Close()
+			return err
+		}
+		rows.Close()
+
+		for _, r := range crypted {
+			raw, err := decryptFlag(r.data, r.eFlag, s.key)
+			if err != nil {
+				return fmt.Errorf("kvlite: Unable to decrypt value in table '%s': %s", table, err.Error())
+			}
+			s.cache.forgetTables(table)
+			if _, err = tx.Exec("UPDATE '"+table+"' SET value = ? WHERE rowid = ?;", encryptFlag(raw, r.eFlag, newKey), r.id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.storedKey {
+		newKey = s.dblocker(tx, s.passphrase, newKey, s.padlock)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	s.key = newKey
+	return nil
+}
+
 var _Store_DRIVER string
 
 func init() {
@@ -459,18 +1877,35 @@ func Open(filePath string, padlock ...[]byte) (*Store, error) {
 	if filePath == NONE {
 		return nil, fmt.Errorf("kvlite: Missing filename parameter.")
 	}
+	return open(filePath, Options{Padlock: joinPadlock(padlock)}, 0)
+}
+
+// Combines multiple padlocks in to one.
+func joinPadlock(padlock [][]byte) []byte {
 	if len(padlock) == 0 {
-		return open(filePath, nil, 0)
-	} else {
-		for i, pad := range padlock {
-			if i == 0 {
-				continue
-			}
-			padlock[0] = append(padlock[0], pad[0:]...)
-			padlock[i] = nil
+		return nil
+	}
+	for i, pad := range padlock {
+		if i == 0 {
+			continue
 		}
-		return open(filePath, padlock[0], 0)
+		padlock[0] = append(padlock[0], pad[0:]...)
+		padlock[i] = nil
+	}
+	return padlock[0]
+}
+
+// Open existing database for reading only, writes will return ErrReadOnly.
+func OpenReadOnly(filePath string, padlock ...[]byte) (*Store, error) {
+	if filePath == NONE {
+		return nil, fmt.Errorf("kvlite: Missing filename parameter.")
 	}
+	return open(filePath, Options{Padlock: joinPadlock(padlock)}, _readonly)
+}
+
+// Open Memory-Only Database with auto-created encryption key, data is lost when the Store is closed.
+func OpenMemory(padlock ...[]byte) (*Store, error) {
+	return open(":memory:", Options{Padlock: joinPadlock(padlock)}, _memory)
 }
 
 var mem_cache_num int32
@@ -487,7 +1922,7 @@ func FastOpen(filePath string, key string) (*Store, error) {
 		key = string(randBytes(32))
 	}
 
-	db, err := open(filePath, nil, _reserved)
+	db, err := open(filePath, Options{}, _reserved)
 	if err != nil {
 		return nil, err
 	}
@@ -495,43 +1930,88 @@ func FastOpen(filePath string, key string) (*Store, error) {
 	return db, nil
 }
 
-func open(filePath string, padlock []byte, flags int) (openStore *Store, err error) {
+// Open a Store using an existing *sql.DB for a SQLite database, will use auto-created encryption key.
+// The Store does not take ownership of db, Close leaves it open for the caller to close.
+// Tables other than the reserved table are not migrated, as db may hold tables of the caller's own, see Migrate.
+func OpenDB(db *sql.DB, padlock ...[]byte) (*Store, error) {
+	if db == nil {
+		return nil, fmt.Errorf("kvlite: Missing database parameter.")
+	}
+	return newStore(db, NONE, joinPadlock(padlock), _shared)
+}
+
+func open(filePath string, opts Options, flags int) (openStore *Store, err error) {
+
+	dsn := filePath
+
+	if flags&_readonly != 0 {
+		dsn = "file:" + filePath + "?mode=ro"
+	}
+
+	conn := &connector{
+		dsn:     dsn,
+		pragmas: opts.pragmas(flags),
+		timeout: opts.busyTimeout(),
+		driver:  &sqlite3.SQLiteDriver{},
+	}
+
+	dbCon := sql.OpenDB(conn)
+
+	if opts.NoMigrate {
+		flags = flags | _nomigrate
+	}
+
+	// Each connection to :memory: is its own database, so keep to a single connection.
+	if flags&_memory != 0 {
+		dbCon.SetMaxOpenConns(1)
+	}
 
-	dbCon, err := sql.Open(_Store_DRIVER, filePath)
+	openStore, err = newStore(dbCon, filePath, opts.Padlock, flags)
 	if err != nil {
 		return nil, err
 	}
+	openStore.connector = conn
+	openStore.caseSensitive = opts.CaseSensitive
+	openStore.encryptAll = opts.EncryptByDefault
+	return
+}
 
-	var buff bytes.Buffer
+// Builds a Store around dbCon, upgrading tables and unlocking the encryption key unless flags say otherwise.
+func newStore(dbCon *sql.DB, filePath string, padlock []byte, flags int) (openStore *Store, err error) {
 
 	openStore = &Store{
 		dbCon:    dbCon,
+		ownsDB:   flags&_shared == 0,
 		filePath: filePath,
-		buffer:   &buff,
-		encoder:  json.NewEncoder(&buff),
+		zipMin:   1024,
+		readOnly: flags&_readonly != 0,
+	}
+
+	// Close a database opened for the Store if it fails to open.
+	if openStore.ownsDB {
+		defer func() {
+			if err != nil {
+				dbCon.Close()
+			}
+		}()
 	}
 
 	if err = dbCon.Ping(); err != nil {
-		dbCon.Close()
-		fmt.Errorf("%s: %s", filePath, err.Error())
-		return nil, err
+		return nil, fmt.Errorf("%s: %s", filePath, err.Error())
 	}
 
-	setPragma := func(input ...string) (err error) {
-		for _, val := range input {
-			_, err = dbCon.Exec(fmt.Sprintf("PRAGMA %s;", val))
-			if err != nil {
-				return err
-			}
+	switch {
+	case openStore.readOnly || flags&_nomigrate != 0:
+		err = openStore.findLegacyTables()
+	case flags&_shared != 0:
+		// A shared database may hold tables of the caller's own, so only the reserved table is upgraded.
+		if err = openStore.upgradeTable(RESERVED); err == nil {
+			err = openStore.findLegacyTables()
 		}
-		return
+	default:
+		err = openStore.upgradeTables()
 	}
-
-	if err := setPragma("case_sensitive_like=OFF",
-		"encoding='UTF-8'",
-		"synchronous=NORMAL",
-		"journal_mode=DELETE",
-	); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
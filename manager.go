@@ -0,0 +1,108 @@
+package kvlite
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// Manager opens Stores by name on demand, keeping at most a set number open and closing the least recently used beyond that.
+// Each Get must be paired with a Release once the caller is finished with the Store, a Store is only closed while unused.
+type Manager struct {
+	mutex  sync.Mutex
+	path   func(name string) string
+	opts   Options
+	max    int
+	order  *list.List
+	stores map[string]*list.Element
+}
+
+// A Store open in a Manager and the number of callers using it.
+type managed struct {
+	name  string
+	store *Store
+	refs  int
+}
+
+// Returns a Manager opening the Store of each name at the file returned by path with opts, keeping at most maxOpen Stores open.
+// A maxOpen of 0 or less keeps all Stores open until Close.
+func NewManager(maxOpen int, path func(name string) string, opts Options) *Manager {
+	return &Manager{
+		path:   path,
+		opts:   opts,
+		max:    maxOpen,
+		order:  list.New(),
+		stores: make(map[string]*list.Element),
+	}
+}
+
+// Returns the Store of name, opening it if not already open, Release must be called once the Store is no longer in use.
+func (m *Manager) Get(name string) (*Store, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stores == nil {
+		return nil, ErrStoreClosed
+	}
+
+	if elem, ok := m.stores[name]; ok {
+		m.order.MoveToFront(elem)
+		entry := elem.Value.(*managed)
+		entry.refs++
+		return entry.store, nil
+	}
+
+	store, err := OpenWithOptions(m.path(name), m.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m.stores[name] = m.order.PushFront(&managed{name, store, 1})
+	m.evict()
+	return store, nil
+}
+
+// Marks the caller finished with the Store of name returned by Get.
+func (m *Manager) Release(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	elem, ok := m.stores[name]
+	if !ok || elem.Value.(*managed).refs == 0 {
+		return fmt.Errorf("kvlite: Store '%s' released more times than it was returned by Get.", name)
+	}
+	elem.Value.(*managed).refs--
+	m.evict()
+	return nil
+}
+
+// Closes least recently used Stores not in use until no more than max remain open.
+func (m *Manager) evict() {
+	if m.max <= 0 {
+		return
+	}
+	for elem := m.order.Back(); elem != nil && m.order.Len() > m.max; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*managed); entry.refs == 0 {
+			entry.store.Close()
+			m.order.Remove(elem)
+			delete(m.stores, entry.name)
+		}
+		elem = prev
+	}
+}
+
+// Closes all open Stores, including those in use, later calls to Get fail with ErrStoreClosed.
+func (m *Manager) Close() (err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, elem := range m.stores {
+		if cerr := elem.Value.(*managed).store.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	m.stores = nil
+	m.order.Init()
+	return
+}
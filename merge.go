@@ -0,0 +1,199 @@
+package kvlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// ConflictMode decides what Merge does with a key that exists in both Stores.
+type ConflictMode int
+
+const (
+	MergeOverwrite ConflictMode = iota // Replace the existing value.
+	MergeSkip                          // Keep the existing value.
+	MergeError                         // Fail the merge of the table with ErrConflict.
+)
+
+// ErrConflict is returned by Merge with MergeError if a key exists in both Stores.
+var ErrConflict = errors.New("kvlite: Key already exists in destination, unable to merge.")
+
+// Copies all tables and keys from other in to the Store, each table is merged in its own transaction.
+// Encrypted values are re-encrypted with the Store's key.
+func (s *Store) Merge(other *Store, onConflict ConflictMode) (err error) {
+	if other == s {
+		return fmt.Errorf("kvlite: Unable to merge a Store in to itself.")
+	}
+
+	// Lock the Stores in address order, so Merges between two Stores in both directions cannot deadlock.
+	if reflect.ValueOf(s).Pointer() < reflect.ValueOf(other).Pointer() {
+		s.mutex.Lock()
+		other.mutex.RLock()
+	} else {
+		other.mutex.RLock()
+		s.mutex.Lock()
+	}
+	defer other.mutex.RUnlock()
+	defer s.mutex.Unlock()
+
+	if s.isClosed() || other.isClosed() {
+		return ErrStoreClosed
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	ctx := context.Background()
+
+	tables, err := allTables(ctx, other.dbCon)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if isReserved(table) {
+			continue
+		}
+		if err = s.mergeTable(ctx, other.dbCon, other, table, onConflict); err != nil {
+			return fmt.Errorf("kvlite: Merge of table '%s' failed: %w", table, err)
+		}
+	}
+	return
+}
+
+// Copies table from src, a connection or transaction of other, in to the Store within a single transaction.
+func (s *Store) mergeTable(ctx context.Context, src dbExec, other *Store, table string, onConflict ConflictMode) (err error) {
+	rows, err := src.QueryContext(ctx, "SELECT key, typeof(key), value, e, "+other.extCol(table, "expires_at")+" FROM '"+table+"';")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	tx, err := s.dbCon.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var changes []txnChange
+
+	for rows.Next() {
+		var (
+			key     string
+			kType   string
+			data    []byte
+			eFlag   int
+			expires int64
+		)
+		if err = rows.Scan(&key, &kType, &data, &eFlag, &expires); err != nil {
+			return err
+		}
+		if expired(expires) {
+			continue
+		}
+
+		if onConflict != MergeOverwrite {
+			_, _, found, err := s.fetch(ctx, tx, table, key)
+			if err != nil {
+				return err
+			}
+			if found && onConflict == MergeSkip {
+				continue
+			}
+			if found {
+				return ErrConflict
+			}
+		}
+
+		raw, err := other.unseal(data, eFlag)
+		if err != nil {
+			return err
+		}
+
+		var k interface{} = key
+		if kType == "integer" {
+			var n int
+			if _, err = fmt.Sscan(key, &n); err != nil {
+				return err
+			}
+			k = n
+		}
+
+		if err = s.chkProjected(table); err != nil {
+			return err
+		}
+
+		eFlag = eFlag&^ePrim | s.defaultCrypt()
+		value := s.seal(raw, eFlag)
+		if err = s.put(ctx, tx, table, k, value, eFlag, expires); err != nil {
+			return err
+		}
+		changes = append(changes, txnChange{table, k, EventSet, value, eFlag})
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	for _, c := range changes {
+		s.notifyValue(c.table, c.key, c.op, c.value, c.eFlag)
+	}
+	return nil
+}
+
+// Copies all tables and keys to a new Store at destPath opened with padlock, returning the open clone.
+// The copy is taken from a consistent snapshot, encrypted values are re-encrypted with the clone's key.
+func (s *Store) Clone(destPath string, padlock ...[]byte) (_ *Store, err error) {
+	if s.isClosed() {
+		return nil, ErrStoreClosed
+	}
+
+	if _, err = os.Stat(destPath); err == nil {
+		return nil, fmt.Errorf("kvlite: %s already exists.", destPath)
+	}
+
+	clone, err := Open(destPath, padlock...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			clone.Close()
+			os.Remove(destPath)
+		}
+	}()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	ctx := context.Background()
+
+	tx, err := s.dbCon.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tables, err := allTables(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range tables {
+		if isReserved(table) {
+			continue
+		}
+		if err = clone.mergeTable(ctx, tx, s, table, MergeOverwrite); err != nil {
+			return nil, fmt.Errorf("kvlite: Clone of table '%s' failed: %w", table, err)
+		}
+	}
+	return clone, nil
+}
@@ -0,0 +1,93 @@
+package kvlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Returns t as unix milliseconds, as stored in the updated_at column.
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Returns the time of unix milliseconds ms, 0 is the zero time, as for keys written before updated_at was tracked.
+func fromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// Retreive a value at key in table along with the time it was last written.
+// updatedAt is the zero time for keys written before last-modified times were tracked.
+func (s *Store) GetWithMeta(table, key string, out interface{}) (found bool, updatedAt time.Time, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return false, updatedAt, err
+	}
+
+	var (
+		data    []byte
+		eFlag   int
+		expires int64
+		updated int64
+	)
+
+	err = s.dbCon.QueryRow("SELECT value, e, "+s.extCol(table, "expires_at")+", "+s.extCol(table, "updated_at")+" FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ?;", key).Scan(&data, &eFlag, &expires, &updated)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return false, updatedAt, nil
+	case err != nil:
+		if isNoTable(err) {
+			return false, updatedAt, nil
+		}
+		return false, updatedAt, err
+	case expired(expires):
+		return false, updatedAt, nil
+	}
+
+	return true, fromMillis(updated), s.decode(table, key, data, eFlag, out)
+}
+
+// List keys in table written after since, ordered from oldest to newest write, for incremental sync.
+// Keys removed since are not listed, and keys written before last-modified times were tracked are never listed.
+func (s *Store) ListKeysModifiedSince(table string, since time.Time) (keyList []string, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tables not yet migrated hold no last-modified times.
+	if s.legacy[strings.ToLower(table)] {
+		return nil, nil
+	}
+
+	rows, err := s.dbCon.QueryContext(context.Background(), "SELECT key FROM '"+table+"' WHERE updated_at > ? AND "+s.unexpired(table)+" ORDER BY updated_at, key;", millis(since), time.Now().Unix())
+	if err != nil {
+		if isNoTable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keyList = append(keyList, key)
+	}
+	return keyList, rows.Err()
+}
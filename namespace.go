@@ -0,0 +1,57 @@
+package kvlite
+
+// Namespace scopes Set, Get, Unset and ListKeys to keys beginning with a prefix.
+type Namespace struct {
+	store  *Store
+	prefix string
+}
+
+// Returns a Namespace over the Store where all keys are prefixed with prefix.
+func (s *Store) Namespace(prefix string) *Namespace {
+	return &Namespace{store: s, prefix: prefix}
+}
+
+// Stores value at prefixed key in table.
+func (n *Namespace) Set(table, key string, val interface{}) error {
+	return n.store.Set(table, n.prefix+key, val)
+}
+
+// Stores value at prefixed key in table, encrypted.
+func (n *Namespace) CryptSet(table, key string, val interface{}) error {
+	return n.store.CryptSet(table, n.prefix+key, val)
+}
+
+// Retreive a value at prefixed key in table.
+func (n *Namespace) Get(table, key string, output interface{}) (found bool, err error) {
+	return n.store.Get(table, n.prefix+key, output)
+}
+
+// Removes prefixed key from table.
+func (n *Namespace) Unset(table, key string) error {
+	return n.store.Unset(table, n.prefix+key)
+}
+
+// List keys in table under prefix with the prefix removed, only those matching filter if specified.
+func (n *Namespace) ListKeys(table string, filters ...string) (keyList []string, err error) {
+	if len(filters) == 0 {
+		filters = []string{"%"}
+	}
+	scoped := make([]string, len(filters))
+	for i, f := range filters {
+		scoped[i] = n.prefix + f
+	}
+
+	keys, err := n.store.ListKeys(table, scoped...)
+	if err != nil {
+		return nil, err
+	}
+
+	// LIKE treats % and _ in prefix as wildcards, so confirm each key really starts with prefix.
+	for _, k := range keys {
+		if len(k) < len(n.prefix) || n.store.foldKey(k[:len(n.prefix)]) != n.store.foldKey(n.prefix) {
+			continue
+		}
+		keyList = append(keyList, k[len(n.prefix):])
+	}
+	return
+}
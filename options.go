@@ -0,0 +1,228 @@
+package kvlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configure a Store opened with OpenWithOptions.
+type Options struct {
+	Padlock          []byte        // Padlock required to open the database, as with Open.
+	JournalMode      string        // SQLite journal mode such as "DELETE" or "WAL", defaults to "DELETE".
+	BusyTimeout      time.Duration // How long to wait on a locked database before failing, defaults to 5 seconds.
+	Synchronous      string        // SQLite synchronous level such as "OFF", "NORMAL" or "FULL", defaults to "NORMAL".
+	CaseSensitive    bool          // Match keys and filters with regard to case, by default "Key1" and "key1" are the same key.
+	NoMigrate        bool          // Skip adding columns missing from tables of older databases on open, such tables can be read but not written until Migrate.
+	EncryptByDefault bool          // Encrypt every value written, as if all writes were made with CryptSet.
+	CreateDirs       bool          // Create any missing parent directories of the database file.
+	PageSize         int           // SQLite page size in bytes, a power of two from 512 to 65536, takes effect only on a new database or after Vacuum outside WAL mode.
+	CacheSize        int           // SQLite page cache size of each connection, in pages if positive or in KiB if negative, as with PRAGMA cache_size.
+}
+
+// Open or Creates a new *Store which encrypts every value written, as if all writes were made with CryptSet.
+func OpenEncrypted(filePath string, padlock []byte) (*Store, error) {
+	return OpenWithOptions(filePath, Options{Padlock: padlock, EncryptByDefault: true})
+}
+
+// Open or Creates a new *Store with the Options specified, will use auto-created encryption key.
+// WAL journal mode lets readers continue while a write is in progress.
+func OpenWithOptions(filePath string, opts Options) (*Store, error) {
+	if filePath == NONE {
+		return nil, fmt.Errorf("kvlite: Missing filename parameter.")
+	}
+	if opts.PageSize != 0 && (opts.PageSize < 512 || opts.PageSize > 65536 || opts.PageSize&(opts.PageSize-1) != 0) {
+		return nil, fmt.Errorf("kvlite: PageSize must be a power of two from 512 to 65536.")
+	}
+	if opts.CreateDirs {
+		if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+			return nil, fmt.Errorf("kvlite: Unable to create directory for %s: %w", filePath, err)
+		}
+	}
+	return open(filePath, opts, 0)
+}
+
+// Returns the PRAGMA statements applied to each new connection.
+func (o Options) pragmas(flags int) []string {
+	journal := o.JournalMode
+	if journal == NONE {
+		journal = "DELETE"
+	}
+
+	synchronous := o.Synchronous
+	if synchronous == NONE {
+		synchronous = "NORMAL"
+	}
+
+	caseSensitive := "OFF"
+	if o.CaseSensitive {
+		caseSensitive = "ON"
+	}
+
+	var pragmas []string
+
+	// The page size must be set before any table is created, and before switching to WAL.
+	if o.PageSize > 0 {
+		pragmas = append(pragmas, "page_size="+strconv.Itoa(o.PageSize))
+	}
+	if o.CacheSize != 0 {
+		pragmas = append(pragmas, "cache_size="+strconv.Itoa(o.CacheSize))
+	}
+
+	pragmas = append(pragmas,
+		"case_sensitive_like="+caseSensitive,
+		"encoding='UTF-8'",
+		"synchronous="+synchronous,
+	)
+
+	// Read-only databases cannot change journal mode.
+	if flags&_readonly == 0 {
+		pragmas = append(pragmas, "journal_mode="+journal)
+	}
+	return pragmas
+}
+
+// Returns the collation used to match keys.
+func (s *Store) collate() string {
+	if s.caseSensitive {
+		return "binary"
+	}
+	return "nocase"
+}
+
+// Returns key folded for comparison as the Store matches keys.
+func (s *Store) foldKey(key string) string {
+	if s.caseSensitive {
+		return key
+	}
+	return strings.ToLower(key)
+}
+
+// Returns the busy timeout, defaulting to 5 seconds.
+func (o Options) busyTimeout() time.Duration {
+	if o.BusyTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return o.BusyTimeout
+}
+
+// connector opens connections to a database, applying pragmas to each connection.
+type connector struct {
+	dsn     string
+	pragmas []string
+	timeout time.Duration
+	logger  QueryLogger
+	mutex   sync.Mutex
+	driver  *sqlite3.SQLiteDriver
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	pragmas := append(append([]string(nil), c.pragmas...), busyPragma(c.timeout))
+	c.mutex.Unlock()
+
+	for _, val := range pragmas {
+		if _, err = conn.(*sqlite3.SQLiteConn).Exec(fmt.Sprintf("PRAGMA %s;", val), nil); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return &logConn{conn.(*sqlite3.SQLiteConn), c}, nil
+}
+
+// Returns the busy_timeout pragma for timeout.
+func busyPragma(timeout time.Duration) string {
+	return fmt.Sprintf("busy_timeout=%d", timeout/time.Millisecond)
+}
+
+// Sets how long to wait on a locked database before failing with "database is locked".
+// Connections in use by an open Cursor keep their previous timeout.
+func (s *Store) SetBusyTimeout(timeout time.Duration) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
+
+	// Stores opened with OpenDB have no connector, only existing connections can be updated.
+	if s.connector != nil {
+		s.connector.mutex.Lock()
+		s.connector.timeout = timeout
+		s.connector.mutex.Unlock()
+	}
+
+	ctx := context.Background()
+
+	// Apply to each idle connection, new connections pick up the timeout when opened.
+	idle := s.dbCon.Stats().Idle
+
+	for i := 0; i < idle; i++ {
+		conn, err := s.dbCon.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if _, err = conn.ExecContext(ctx, "PRAGMA "+busyPragma(timeout)+";"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sets the maximum number of open connections to the database, n <= 0 means unlimited.
+// SQLite allows a single writer at a time, so extra connections only help concurrent readers,
+// and with the default DELETE journal mode a write still waits on all readers, WAL mode suits larger pools.
+// Stores opened with OpenMemory always use a single connection, as each connection would be a separate database.
+func (s *Store) SetMaxOpenConns(n int) {
+	if s.filePath == ":memory:" {
+		return
+	}
+	s.dbCon.SetMaxOpenConns(n)
+}
+
+// Sets the maximum number of idle connections kept open for reuse, n <= 0 keeps no idle connections.
+// Ignored by Stores opened with OpenMemory, closing the connection would discard the database.
+func (s *Store) SetMaxIdleConns(n int) {
+	if s.filePath == ":memory:" {
+		return
+	}
+	s.dbCon.SetMaxIdleConns(n)
+}
+
+// Flushes committed writes to the database file, checkpointing the WAL when in WAL journal mode.
+// With WAL and the default NORMAL level, recent commits can be lost on power failure until checkpointed.
+func (s *Store) Sync() (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
+
+	var mode string
+	if err = s.dbCon.QueryRow("PRAGMA journal_mode;").Scan(&mode); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(mode, "wal") {
+		_, err = s.dbCon.Exec("PRAGMA wal_checkpoint(FULL);")
+	}
+	return
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
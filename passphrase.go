@@ -0,0 +1,194 @@
+package kvlite
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+const (
+	kdfTable = "KVLite_KDF" // Reserved table holding the salt used by OpenWithPassphrase.
+	saltLen  = 16
+)
+
+// scrypt cost parameters used to stretch passphrases.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Open or Creates a new *Store locked with a padlock derived from passphrase using scrypt.
+// The salt is stored in the database when first created, a nil salt will generate a random salt.
+// On later opens the stored salt is used and salt is ignored.
+func OpenWithPassphrase(filePath, passphrase string, salt []byte) (*Store, error) {
+	if filePath == NONE {
+		return nil, fmt.Errorf("kvlite: Missing filename parameter.")
+	}
+
+	Stor, err := open(filePath, Options{}, _reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, stored, err := Stor.kdfSalt(salt)
+	if err != nil {
+		Stor.Close()
+		return nil, err
+	}
+
+	padlock, err := scryptKey([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		Stor.Close()
+		return nil, err
+	}
+
+	if err = Stor.dbunlocker(padlock); err != nil {
+		Stor.Close()
+		return nil, err
+	}
+
+	// Only keep a new salt once it has unlocked the database.
+	if !stored {
+		if err = Stor.put(context.Background(), Stor.dbCon, kdfTable, "salt", Stor.seal(salt, 0), 0, 0); err != nil {
+			Stor.Close()
+			return nil, err
+		}
+	}
+	return Stor, nil
+}
+
+// Returns the salt stored in the database, or salt or a random salt if none is stored yet, stored is false for the latter.
+func (s *Store) kdfSalt(salt []byte) (_ []byte, stored bool, err error) {
+	data, eFlag, found, err := s.fetch(context.Background(), s.dbCon, kdfTable, "salt")
+	if err != nil {
+		return nil, false, err
+	}
+	if found {
+		salt, err = s.unseal(data, eFlag)
+		return salt, true, err
+	}
+
+	if len(salt) == 0 {
+		salt = make([]byte, saltLen)
+		if _, err = rand.Read(salt); err != nil {
+			return nil, false, err
+		}
+	}
+	return salt, false, nil
+}
+
+// Derives a key of keyLen bytes from password and salt with scrypt as specified by RFC 7914, N must be a power of 2 greater than 1.
+func scryptKey(password, salt []byte, N, r, p, keyLen int) ([]byte, error) {
+	if N <= 1 || N&(N-1) != 0 {
+		return nil, fmt.Errorf("kvlite: scrypt N must be a power of 2 greater than 1.")
+	}
+
+	b, err := pbkdf2.Key(sha256.New, string(password), salt, 1, p*128*r)
+	if err != nil {
+		return nil, err
+	}
+
+	x := make([]uint32, 32*r)
+	v := make([]uint32, 32*r*N)
+
+	for i := 0; i < p; i++ {
+		scryptROMix(b[i*128*r:(i+1)*128*r], x, v, N, r)
+	}
+
+	return pbkdf2.Key(sha256.New, string(password), b, 1, keyLen)
+}
+
+// Mixes block b of 128*r bytes in place using x and v as scratch space, the scryptROMix function of RFC 7914.
+func scryptROMix(b []byte, x, v []uint32, N, r int) {
+	n := 32 * r
+
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(b[i*4:])
+	}
+
+	for i := 0; i < N; i++ {
+		copy(v[i*n:], x)
+		scryptBlockMix(x, r)
+	}
+
+	for i := 0; i < N; i++ {
+		j := int(x[(2*r-1)*16] & uint32(N-1))
+		for k, w := range v[j*n : (j+1)*n] {
+			x[k] ^= w
+		}
+		scryptBlockMix(x, r)
+	}
+
+	for i, w := range x {
+		binary.LittleEndian.PutUint32(b[i*4:], w)
+	}
+}
+
+// Mixes the 2*r 64 byte blocks of b in place, the scryptBlockMix function of RFC 7914.
+func scryptBlockMix(b []uint32, r int) {
+	var t [16]uint32
+
+	y := make([]uint32, len(b))
+	copy(t[:], b[(2*r-1)*16:])
+
+	for i := 0; i < 2*r; i++ {
+		for k := range t {
+			t[k] ^= b[i*16+k]
+		}
+		salsa208(&t)
+
+		// Even blocks go to the first half of the output, odd blocks to the second.
+		copy(y[(i/2+(i%2)*r)*16:], t[:])
+	}
+	copy(b, y)
+}
+
+// Applies the Salsa20/8 core to b.
+func salsa208(b *[16]uint32) {
+	x := *b
+
+	for i := 0; i < 8; i += 2 {
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+
+	for i := range b {
+		b[i] += x[i]
+	}
+}
@@ -0,0 +1,78 @@
+package kvlite
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Bits of the e column marking a value stored as a native SQLite type.
+const (
+	eText   = 1 << (iota + 5) // Value is a string stored as TEXT.
+	eNumber                   // Value is a number stored as INTEGER or REAL.
+	eBool                     // Value is a bool stored as INTEGER 0 or 1.
+
+	ePrim = eText | eNumber | eBool
+)
+
+// Returns val as a native SQLite value with its e column bits, ok is false if val is not a primitive.
+func native(val interface{}) (value interface{}, eFlag int, ok bool) {
+	switch v := val.(type) {
+	case string:
+		// The driver reads TEXT only up to the first NUL, so such strings are encoded instead.
+		if strings.IndexByte(v, 0) >= 0 {
+			return nil, 0, false
+		}
+		return v, eText, true
+	case bool:
+		if v {
+			return 1, eBool, true
+		}
+		return 0, eBool, true
+	case int:
+		return int64(v), eNumber, true
+	case int32:
+		return int64(v), eNumber, true
+	case int64:
+		return v, eNumber, true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, 0, false
+		}
+		return v, eNumber, true
+	}
+	return nil, 0, false
+}
+
+// Returns the JSON encoding of a native value read back from the value column.
+func fromNative(data []byte, eFlag int) ([]byte, error) {
+	switch {
+	case eFlag&eText != 0:
+		raw, err := json.Marshal(string(data))
+		if err != nil {
+			return nil, err
+		}
+		return append(raw, '\n'), nil
+	case eFlag&eBool != 0:
+		if string(data) == "0" {
+			return []byte("false\n"), nil
+		}
+		return []byte("true\n"), nil
+	}
+	return append(append([]byte(nil), data...), '\n'), nil
+}
+
+// Returns the value to store for data read from the value column as text, restoring the SQLite type of native values.
+func toNative(data []byte, eFlag int) (value interface{}, err error) {
+	switch {
+	case eFlag&eText != 0:
+		return string(data), nil
+	case eFlag&(eNumber|eBool) != 0:
+		if value, err = strconv.ParseInt(string(data), 10, 64); err != nil {
+			return strconv.ParseFloat(string(data), 64)
+		}
+		return value, nil
+	}
+	return data, nil
+}
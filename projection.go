@@ -0,0 +1,221 @@
+package kvlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A value extracted from values written to a table, stored in a column of its own.
+type projection struct {
+	name    string
+	extract func(val interface{}) interface{}
+}
+
+// Returns the column holding projection name.
+func projColumn(name string) string {
+	return "p_" + name
+}
+
+// Returns an error if name may not be used as a projection name.
+func chkProjName(name string) error {
+	if name == NONE {
+		return fmt.Errorf("kvlite: Missing projection name.")
+	}
+	for _, ch := range name {
+		if ch > unicode.MaxASCII || (!unicode.IsLetter(ch) && !unicode.IsDigit(ch) && ch != '_') {
+			return fmt.Errorf("kvlite: Invalid characters in projection name: '%s'", name)
+		}
+	}
+	return nil
+}
+
+// Registers extract to be called with each value written to table, storing the result in a column named by name for ListKeysWhere.
+// extract must return a string, number, bool, []byte, time.Time or nil, and is stored unencrypted even for values written with CryptSet.
+// Values already in table have no projected value until next written, methods writing only stored values, such as SetRaw, fail with ErrProjected.
+func (s *Store) AddProjection(table, name string, extract func(val interface{}) interface{}) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	if err = s.chkTable(&table, 0); err != nil {
+		return err
+	}
+	if err = chkProjName(name); err != nil {
+		return err
+	}
+
+	if s.projections == nil {
+		s.projections = make(map[string][]projection)
+	}
+
+	tkey := strings.ToLower(table)
+	projs := s.projections[tkey]
+
+	var replaced bool
+
+	for i := range projs {
+		if projs[i].name == name {
+			projs[i].extract = extract
+			replaced = true
+		}
+	}
+	if !replaced {
+		projs = append(projs, projection{name, extract})
+	}
+
+	exists, err := s.tableExists(table)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if err = s.addProjColumns(context.Background(), s.dbCon, table, projs); err != nil {
+			return err
+		}
+	}
+
+	s.projections[tkey] = projs
+	return nil
+}
+
+// Adds the columns of projs missing from table.
+func (s *Store) addProjColumns(ctx context.Context, db dbExec, table string, projs []projection) (err error) {
+	existing, err := tableColumns(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	for _, p := range projs {
+		if existing[projColumn(p.name)] {
+			continue
+		}
+		if _, err = db.ExecContext(ctx, "ALTER TABLE '"+table+"' ADD COLUMN "+projColumn(p.name)+";"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErrProjected is returned when a value is written to a table with projections by a method that has only its stored form, such as SetRaw, Import or Merge.
+var ErrProjected = errors.New("kvlite: Table has projections, values must be written from their Go value, such as with Set.")
+
+// Returns the projections of table.
+func (s *Store) projectionsOf(table string) []projection {
+	return s.projections[strings.ToLower(table)]
+}
+
+// Fails with ErrProjected if table has projections, for writes that cannot compute them.
+func (s *Store) chkProjected(table string) error {
+	if len(s.projectionsOf(table)) > 0 {
+		return fmt.Errorf("%w: '%s'", ErrProjected, table)
+	}
+	return nil
+}
+
+// Writes value at key as put does, along with the values projected from val, in a single transaction.
+func (s *Store) putProjected(ctx context.Context, table string, key, val, value interface{}, eFlag int, expires int64) (err error) {
+	tx, err := s.dbCon.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = s.put(ctx, tx, table, key, value, eFlag, expires); err != nil {
+		return err
+	}
+	if err = s.project(ctx, tx, table, key, val); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Updates the projected columns of key in table from val, the Go value just written at key.
+func (s *Store) project(ctx context.Context, db dbExec, table string, key, val interface{}) (err error) {
+	projs := s.projectionsOf(table)
+	if len(projs) == 0 {
+		return nil
+	}
+
+	cols := make([]string, len(projs))
+	args := make([]interface{}, 0, len(projs)+1)
+
+	for i, p := range projs {
+		cols[i] = projColumn(p.name) + " = ?"
+		args = append(args, p.extract(val))
+	}
+	if k, ok := key.([]byte); ok {
+		args = append(args, k)
+	} else {
+		args = append(args, fmt.Sprintf("%v", key))
+	}
+
+	query := "UPDATE '" + table + "' SET " + strings.Join(cols, ", ") + " WHERE key COLLATE " + s.collate() + " = ?;"
+
+	// The table may have been created since the projection was added, without its columns.
+	if _, err = db.ExecContext(ctx, query, args...); err != nil {
+		if err = s.addProjColumns(ctx, db, table, projs); err != nil {
+			return err
+		}
+		_, err = db.ExecContext(ctx, query, args...)
+	}
+	return err
+}
+
+// Comparison operators accepted by ListKeysWhere.
+var projOps = map[string]bool{
+	"=":    true,
+	"!=":   true,
+	"<":    true,
+	"<=":   true,
+	">":    true,
+	">=":   true,
+	"LIKE": true,
+}
+
+// Lists keys in table whose value projected by the projection name compares to value with op, one of =, !=, <, <=, >, >= or LIKE.
+// Keys without a projected value never match, the projection need not be added by this Store, only to the table.
+func (s *Store) ListKeysWhere(table, name, op string, value interface{}) (keyList []string, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if err = s.chkTable(&table, 0); err != nil {
+		return nil, err
+	}
+	if err = chkProjName(name); err != nil {
+		return nil, err
+	}
+
+	op = strings.ToUpper(op)
+	if !projOps[op] {
+		return nil, fmt.Errorf("kvlite: Unsupported operator '%s'.", op)
+	}
+
+	rows, err := s.dbCon.Query("SELECT key FROM '"+table+"' WHERE "+projColumn(name)+" "+op+" ? AND "+s.unexpired(table)+s.orderBy(_sort)+";", value, time.Now().Unix())
+	if err != nil {
+		if isNoTable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keyList = append(keyList, key)
+	}
+	return keyList, rows.Err()
+}
@@ -0,0 +1,83 @@
+package kvlite
+
+import (
+	"database/sql/driver"
+	"github.com/mattn/go-sqlite3"
+	"time"
+)
+
+// QueryLogger receives each statement run against the database, its bound arguments and how long it took.
+type QueryLogger func(query string, args []interface{}, dur time.Duration)
+
+// Sets fn to be called after every statement the Store runs, a nil fn stops logging.
+// Has no effect on Stores opened with OpenDB.
+func (s *Store) SetQueryLogger(fn func(query string, args []interface{}, dur time.Duration)) {
+	if s.connector == nil {
+		return
+	}
+	s.connector.mutex.Lock()
+	defer s.connector.mutex.Unlock()
+	s.connector.logger = fn
+}
+
+// Returns the current QueryLogger, if any.
+func (c *connector) queryLogger() QueryLogger {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.logger
+}
+
+// Passes query, args and the time since start to the QueryLogger, if one is set.
+func (c *connector) logQuery(query string, args []driver.Value, start time.Time) {
+	logger := c.queryLogger()
+	if logger == nil {
+		return
+	}
+
+	vals := make([]interface{}, len(args))
+	for i, v := range args {
+		vals[i] = v
+	}
+	logger(query, vals, time.Since(start))
+}
+
+// logConn wraps a connection, reporting statements to the connector's QueryLogger.
+type logConn struct {
+	*sqlite3.SQLiteConn
+	connector *connector
+}
+
+func (c *logConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	defer c.connector.logQuery(query, args, time.Now())
+	return c.SQLiteConn.Exec(query, args)
+}
+
+func (c *logConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	defer c.connector.logQuery(query, args, time.Now())
+	return c.SQLiteConn.Query(query, args)
+}
+
+func (c *logConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := c.SQLiteConn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &logStmt{stmt, query, c.connector}, nil
+}
+
+// logStmt wraps a prepared statement, reporting each execution to the connector's QueryLogger.
+type logStmt struct {
+	driver.Stmt
+	query     string
+	connector *connector
+}
+
+func (s *logStmt) Exec(args []driver.Value) (driver.Result, error) {
+	defer s.connector.logQuery(s.query, args, time.Now())
+	return s.Stmt.Exec(args)
+}
+
+func (s *logStmt) Query(args []driver.Value) (driver.Rows, error) {
+	defer s.connector.logQuery(s.query, args, time.Now())
+	return s.Stmt.Query(args)
+}
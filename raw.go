@@ -0,0 +1,67 @@
+package kvlite
+
+import (
+	"context"
+)
+
+// RawFlags records how a value returned by GetRaw was encoded, it must be passed unchanged to SetRaw.
+type RawFlags int
+
+// Returns true if the raw value is encrypted, it can then only be read by a Store holding the same encryption key.
+func (f RawFlags) Encrypted() bool {
+	return f&eCrypt != 0
+}
+
+// Retreive the value at key in table exactly as stored, without decrypting, decompressing or decoding it, for replication with SetRaw.
+func (s *Store) GetRaw(table, key string) (data []byte, flags RawFlags, found bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	data, eFlag, found, err := s.fetch(context.Background(), s.dbCon, table, key)
+	if err != nil || !found {
+		return nil, 0, false, err
+	}
+	return data, RawFlags(eFlag), true, nil
+}
+
+// Stores data and flags returned by GetRaw at key in table, encrypted values are only readable if the Store holds the same encryption key.
+func (s *Store) SetRaw(table, key string, data []byte, flags RawFlags) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return err
+	}
+	if err = s.chkProjected(table); err != nil {
+		return err
+	}
+
+	// Native values are returned by GetRaw as text, restore their SQLite type.
+	value, err := toNative(data, int(flags))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	err = s.retry(ctx, func() error {
+		return s.put(ctx, s.dbCon, table, key, value, int(flags), 0)
+	})
+	if err != nil {
+		return err
+	}
+	s.notifyValue(table, key, EventSet, value, int(flags))
+	return nil
+}
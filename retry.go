@@ -0,0 +1,47 @@
+package kvlite
+
+import (
+	"context"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"time"
+)
+
+// Sets how many times set, unset and truncate retry a write that fails with "database is locked",
+// waiting base before the first retry and doubling the wait after each, attempts of 0 disables retries.
+func (s *Store) SetRetryPolicy(attempts int, base time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.retryAttempts = attempts
+	s.retryBase = base
+}
+
+// Returns true if err is SQLITE_BUSY or SQLITE_LOCKED.
+func isBusy(err error) bool {
+	var sqlErr sqlite3.Error
+	if errors.As(err, &sqlErr) {
+		return sqlErr.Code == sqlite3.ErrBusy || sqlErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// Runs fn, retrying with exponential backoff per the retry policy while fn fails with a busy error.
+// fn must be safe to repeat, retries stop early if ctx is done.
+func (s *Store) retry(ctx context.Context, fn func() error) (err error) {
+	wait := s.retryBase
+
+	for i := 0; ; i++ {
+		if err = fn(); err == nil || i >= s.retryAttempts || !isBusy(err) {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		wait = wait * 2
+	}
+}
@@ -0,0 +1,103 @@
+package kvlite
+
+import (
+	"time"
+)
+
+// Stats summarizes the contents of a Store, excluding reserved tables.
+type Stats struct {
+	TableCount int               // Number of tables.
+	TotalKeys  uint64            // Number of keys across all tables.
+	FileSize   int64             // Size of the database in bytes.
+	Tables     map[string]uint32 // Number of keys in each table.
+}
+
+// Returns statistics on tables, keys and storage used by the Store.
+func (s *Store) Stats() (stats Stats, err error) {
+	tables, err := s.ListTables()
+	if err != nil {
+		return stats, err
+	}
+
+	stats.Tables = make(map[string]uint32)
+
+	for _, table := range tables {
+		count, err := s.CountKeys(table)
+		if err != nil {
+			return stats, err
+		}
+		stats.Tables[table] = count
+		stats.TotalKeys = stats.TotalKeys + uint64(count)
+	}
+	stats.TableCount = len(tables)
+
+	stats.FileSize, err = s.Size()
+	return
+}
+
+// TableSummary describes a single table of a Store.
+type TableSummary struct {
+	Name           string // Name of the table.
+	KeyCount       uint32 // Number of keys in the table.
+	EstimatedBytes int64  // Total size of stored values, excluding keys and SQLite overhead.
+}
+
+// Returns the name, key count and value size of each table, excluding reserved tables and expired keys.
+func (s *Store) TableSummaries() (summaries []TableSummary, err error) {
+	tables, err := s.ListTables()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, table := range tables {
+		summary := TableSummary{Name: table}
+		err = s.dbCon.QueryRow("SELECT COUNT(key), COALESCE(SUM(LENGTH(CAST(value AS BLOB))), 0) FROM '"+table+"' WHERE "+s.unexpired(table)+";", time.Now().Unix()).Scan(&summary.KeyCount, &summary.EstimatedBytes)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return
+}
+
+// Returns the number of encrypted and plaintext values in table, expired keys not yet removed are counted as they remain on disk.
+func (s *Store) CountByEncryption(table string) (encrypted, plaintext int, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = s.dbCon.QueryRow("SELECT COALESCE(SUM(e & ? != 0), 0), COALESCE(SUM(e & ? = 0), 0) FROM '"+table+"';", eCrypt, eCrypt).Scan(&encrypted, &plaintext)
+	if err != nil {
+		if isNoTable(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	return
+}
+
+// Returns the number of encrypted and plaintext values across all tables, excluding reserved tables.
+func (s *Store) CountAllByEncryption() (encrypted, plaintext int, err error) {
+	tables, err := s.ListTables()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, table := range tables {
+		e, p, err := s.CountByEncryption(table)
+		if err != nil {
+			return 0, 0, err
+		}
+		encrypted = encrypted + e
+		plaintext = plaintext + p
+	}
+	return
+}
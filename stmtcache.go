@@ -0,0 +1,111 @@
+package kvlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// Maximum number of tables with cached statements, queries against further tables are not prepared.
+const maxStmtTables = 64
+
+// Returns a prepared statement for query against table, preparing and caching it on first use.
+// Statements are cached by table name without regard to case, as SQLite matches table names, nil is returned once the cache is full.
+func (s *Store) prepared(ctx context.Context, table, query string) (*sql.Stmt, error) {
+	s.stmtMutex.Lock()
+	defer s.stmtMutex.Unlock()
+
+	table = strings.ToLower(table)
+
+	if stmt, ok := s.stmts[table][query]; ok {
+		return stmt, nil
+	}
+	if s.stmts[table] == nil && len(s.stmts) >= maxStmtTables {
+		return nil, nil
+	}
+
+	stmt, err := s.dbCon.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.stmts == nil {
+		s.stmts = make(map[string]map[string]*sql.Stmt)
+	}
+	if s.stmts[table] == nil {
+		s.stmts[table] = make(map[string]*sql.Stmt)
+	}
+	s.stmts[table][query] = stmt
+	return stmt, nil
+}
+
+// Runs query against table on db, using a cached prepared statement when db is the Store's own database.
+func (s *Store) exec(ctx context.Context, db dbExec, table, query string, args ...interface{}) (sql.Result, error) {
+	if db != dbExec(s.dbCon) {
+		return db.ExecContext(ctx, query, args...)
+	}
+	stmt, err := s.prepared(ctx, table, query)
+	if err != nil {
+		return nil, err
+	}
+	if stmt == nil {
+		return db.ExecContext(ctx, query, args...)
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+// Runs query returning a single row against table on db, using a cached prepared statement when db is the Store's own database.
+func (s *Store) queryRow(ctx context.Context, db dbExec, table, query string, args ...interface{}) rowScanner {
+	if db != dbExec(s.dbCon) {
+		return db.QueryRowContext(ctx, query, args...)
+	}
+	stmt, err := s.prepared(ctx, table, query)
+	if err != nil {
+		return errRow{err}
+	}
+	if stmt == nil {
+		return db.QueryRowContext(ctx, query, args...)
+	}
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+// rowScanner is implemented by *sql.Row.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// errRow is a rowScanner returning err, for a statement that failed to prepare.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...interface{}) error {
+	return r.err
+}
+
+// Closes and forgets cached statements for each of tables.
+func (s *Store) forgetStmts(tables ...string) {
+	s.stmtMutex.Lock()
+	defer s.stmtMutex.Unlock()
+
+	for _, table := range tables {
+		table = strings.ToLower(table)
+		for _, stmt := range s.stmts[table] {
+			stmt.Close()
+		}
+		delete(s.stmts, table)
+	}
+}
+
+// Closes and forgets all cached statements.
+func (s *Store) forgetAllStmts() {
+	s.stmtMutex.Lock()
+	defer s.stmtMutex.Unlock()
+
+	for _, stmts := range s.stmts {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}
+	s.stmts = nil
+}
@@ -0,0 +1,277 @@
+package kvlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Returns true if table exists in the database, table names match without regard to case as in SQLite.
+func (s *Store) tableExists(table string) (exists bool, err error) {
+	var count int
+	err = s.dbCon.QueryRow("SELECT COUNT(name) FROM sqlite_master WHERE type='table' AND name = ? COLLATE NOCASE;", table).Scan(&count)
+	return count > 0, err
+}
+
+// Returns true if table exists in the Store.
+func (s *Store) TableExists(table string) (exists bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if err = s.chkTable(&table, _reserved); err != nil {
+		return false, err
+	}
+
+	return s.tableExists(table)
+}
+
+// Renames table old to new, fails if new already exists.
+func (s *Store) RenameTable(old, new string) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	if err = s.chkTable(&old, 0); err != nil {
+		return err
+	}
+	if err = s.chkTable(&new, 0); err != nil {
+		return err
+	}
+
+	exists, err := s.tableExists(new)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("kvlite: Table '%s' already exists.", new)
+	}
+
+	s.forgetStmts(old, new)
+	s.cache.forgetTables(old, new)
+
+	_, err = s.dbCon.Exec("ALTER TABLE '" + old + "' RENAME TO '" + new + "';")
+	if isNoTable(err) {
+		return fmt.Errorf("%w: '%s'", ErrNoSuchTable, old)
+	}
+	return err
+}
+
+// Copies all keys in table src to a new table dst, fails if dst already exists.
+func (s *Store) CopyTable(src, dst string) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	if err = s.chkTable(&src, 0); err != nil {
+		return err
+	}
+	if err = s.chkTable(&dst, 0); err != nil {
+		return err
+	}
+	if err = s.chkProjected(dst); err != nil {
+		return err
+	}
+
+	exists, err := s.tableExists(dst)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("kvlite: Table '%s' already exists.", dst)
+	}
+
+	tx, err := s.dbCon.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("CREATE TABLE '" + dst + "' (" + tableDef(NONE) + ");"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec("INSERT INTO '" + dst + "'(key,value,e,expires_at,updated_at,version) SELECT key, value, e, expires_at, updated_at, version FROM '" + src + "';"); err != nil {
+		tx.Rollback()
+		if isNoTable(err) {
+			return fmt.Errorf("%w: '%s'", ErrNoSuchTable, src)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Drops all tables in a single transaction, the Store's encryption key is kept.
+func (s *Store) Reset() (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isClosed() {
+		return ErrStoreClosed
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.dbCon.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	tables, err := allTables(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	var dropped []string
+
+	for _, table := range tables {
+		if isReserved(table) {
+			continue
+		}
+		s.forgetStmts(table)
+		s.cache.forgetTables(table)
+		if _, err = tx.ExecContext(ctx, "DROP TABLE '"+table+"';"); err != nil {
+			return err
+		}
+		dropped = append(dropped, table)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	for _, table := range dropped {
+		s.publish(Change{Table: table, Op: EventTruncate}, nil, 0)
+	}
+	return nil
+}
+
+// Drops all tables with names matching the LIKE filter in a single transaction, returning the names of the tables dropped.
+// Reserved tables are never dropped, tables already dropped by another connection are passed over.
+func (s *Store) TruncateMatching(filter string) (dropped []string, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isClosed() {
+		return nil, ErrStoreClosed
+	}
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	ctx := context.Background()
+
+	tx, err := s.dbCon.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' AND name LIKE ?;", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []string
+
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if !isReserved(table) {
+			tables = append(tables, table)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, table := range tables {
+		s.forgetStmts(table)
+		s.cache.forgetTables(table)
+		if _, err = tx.ExecContext(ctx, "DROP TABLE IF EXISTS '"+table+"';"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		s.publish(Change{Table: table, Op: EventTruncate}, nil, 0)
+	}
+	return tables, nil
+}
+
+// Returns the tables in which key exists.
+func (s *Store) FindKey(key string) (tables []string, err error) {
+	list, err := s.ListTables()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, table := range list {
+		var exists bool
+		err = s.dbCon.QueryRow("SELECT EXISTS(SELECT 1 FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ? AND "+s.unexpired(table)+");", key, time.Now().Unix()).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			tables = append(tables, table)
+		}
+	}
+	return
+}
+
+// Creates a case-insensitive index on the keys of table, if one does not already exist.
+// Stores opened with CaseSensitive need no index, the primary key serves the same lookups.
+// The index serves lookups by key and filters with a fixed prefix such as "tenant42:%",
+// filters beginning with a wildcard such as "%:config" still scan the whole table.
+func (s *Store) EnsureIndex(table string) (err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	if err = s.chkTable(&table, 0); err != nil {
+		return err
+	}
+
+	if s.caseSensitive {
+		return nil
+	}
+
+	_, err = s.dbCon.Exec("CREATE INDEX IF NOT EXISTS '" + table + "_key_nocase' ON '" + table + "' (key COLLATE NOCASE);")
+	return err
+}
@@ -0,0 +1,79 @@
+package kvlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Stores value in Store datastore, value will expire after ttl, expired keys read as missing until removed by ReapExpired.
+func (s *Store) SetWithTTL(table, key string, val interface{}, ttl time.Duration) (err error) {
+	return s.set(context.Background(), table, key, val, 0, ttl)
+}
+
+// Returns true if expires is set and has passed.
+func expired(expires int64) bool {
+	return expires > 0 && expires <= time.Now().Unix()
+}
+
+// Removes all expired keys from all tables, returns number of keys removed.
+func (s *Store) ReapExpired() (count int, err error) {
+	tables, err := s.ListTables()
+	if err != nil {
+		return 0, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	now := time.Now().Unix()
+
+	for _, table := range tables {
+		// Tables not yet migrated hold no keys that expire.
+		if s.legacy[strings.ToLower(table)] {
+			continue
+		}
+		n, err := s.unsetWhere(table, "expires_at > 0 AND expires_at <= ?", now)
+		if err != nil {
+			return count, err
+		}
+		count = count + n
+	}
+	return
+}
+
+// Returns the time remaining until key in table expires, a ttl of 0 means key never expires, found is false if key is missing or expired.
+func (s *Store) GetTTL(table, key string) (ttl time.Duration, found bool, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var expires int64
+
+	err = s.dbCon.QueryRow("SELECT "+s.extCol(table, "expires_at")+" FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ?;", key).Scan(&expires)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, false, nil
+	case err != nil:
+		if isNoTable(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	case expires == 0:
+		return 0, true, nil
+	case expired(expires):
+		return 0, false, nil
+	}
+	return time.Until(time.Unix(expires, 0)), true, nil
+}
@@ -0,0 +1,194 @@
+package kvlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrTxnDone is returned if a Txn is used after Commit or Rollback.
+var ErrTxnDone = errors.New("kvlite: Transaction has already been committed or rolled back.")
+
+// Txn is a transaction against the Store, holding the Store's write lock until Commit or Rollback.
+// The Store itself must not be used by the goroutine holding an open Txn.
+type Txn struct {
+	store     *Store
+	tx        *sql.Tx
+	parent    *Txn
+	savepoint string
+	seq       *int
+	changes   []txnChange
+}
+
+// A write made within a Txn, reported to watchers and subscribers once the Txn commits.
+type txnChange struct {
+	table string
+	key   interface{}
+	op    EventOp
+	value interface{}
+	eFlag int
+}
+
+// Begins a transaction, reads within the transaction see its own uncommitted writes.
+func (s *Store) Begin() (*Txn, error) {
+	s.mutex.Lock()
+
+	if s.isClosed() {
+		s.mutex.Unlock()
+		return nil, ErrStoreClosed
+	}
+	if s.readOnly {
+		s.mutex.Unlock()
+		return nil, ErrReadOnly
+	}
+
+	tx, err := s.dbCon.Begin()
+	if err != nil {
+		s.mutex.Unlock()
+		return nil, err
+	}
+
+	return &Txn{store: s, tx: tx, seq: new(int)}, nil
+}
+
+// Begins a nested transaction within t using a savepoint, which can be committed or rolled back without ending t.
+// Writes committed by the nested transaction are still discarded if t is rolled back.
+func (t *Txn) Begin() (*Txn, error) {
+	if !t.active() {
+		return nil, ErrTxnDone
+	}
+
+	*t.seq++
+	savepoint := fmt.Sprintf("kvlite_%d", *t.seq)
+
+	if _, err := t.tx.Exec("SAVEPOINT " + savepoint + ";"); err != nil {
+		return nil, err
+	}
+
+	return &Txn{store: t.store, tx: t.tx, parent: t, savepoint: savepoint, seq: t.seq}, nil
+}
+
+// Returns true if neither t nor any transaction it is nested within has ended.
+func (t *Txn) active() bool {
+	return t.tx != nil && (t.parent == nil || t.parent.active())
+}
+
+// Stores value in Store datastore within the transaction.
+func (t *Txn) Set(table string, key interface{}, val interface{}) (err error) {
+	if !t.active() {
+		return ErrTxnDone
+	}
+
+	err = t.store.chkTable(&table, 0)
+	if err != nil {
+		return err
+	}
+
+	value, eFlag, err := t.store.encode(val, 0)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if err = t.store.put(ctx, t.tx, table, key, value, eFlag, 0); err != nil {
+		return err
+	}
+	if err = t.store.project(ctx, t.tx, table, key, val); err != nil {
+		return err
+	}
+	t.changes = append(t.changes, txnChange{table, key, EventSet, value, eFlag})
+	return nil
+}
+
+// Unset/remove key in table specified within the transaction.
+func (t *Txn) Unset(table string, key interface{}) (err error) {
+	if !t.active() {
+		return ErrTxnDone
+	}
+
+	err = t.store.chkTable(&table, 0)
+	if err != nil {
+		return err
+	}
+
+	t.store.uncache(table, key)
+
+	result, err := t.tx.Exec("DELETE FROM '"+table+"' WHERE key COLLATE "+t.store.collate()+" = ?;", key)
+	if err != nil {
+		if isNoTable(err) {
+			return nil
+		}
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		t.changes = append(t.changes, txnChange{table: table, key: key, op: EventUnset})
+	}
+	return nil
+}
+
+// Retreive a value at key in table specified within the transaction.
+func (t *Txn) Get(table string, key interface{}, output interface{}) (found bool, err error) {
+	if !t.active() {
+		return false, ErrTxnDone
+	}
+
+	err = t.store.chkTable(&table, _reserved)
+	if err != nil {
+		return false, err
+	}
+
+	data, eFlag, found, err := t.store.fetch(context.Background(), t.tx, table, key)
+	if err != nil || !found {
+		return false, err
+	}
+
+	return true, t.store.decode(table, key, data, eFlag, output)
+}
+
+// Commits the transaction and releases the Store, a nested transaction is released in to the transaction it was begun from.
+func (t *Txn) Commit() error {
+	if !t.active() {
+		return ErrTxnDone
+	}
+	defer t.done()
+	if t.parent != nil {
+		if _, err := t.tx.Exec("RELEASE SAVEPOINT " + t.savepoint + ";"); err != nil {
+			return err
+		}
+		t.parent.changes = append(t.parent.changes, t.changes...)
+		return nil
+	}
+	if err := t.tx.Commit(); err != nil {
+		return err
+	}
+	for _, c := range t.changes {
+		t.store.notifyValue(c.table, c.key, c.op, c.value, c.eFlag)
+	}
+	return nil
+}
+
+// Rolls back the transaction and releases the Store, a nested transaction only undoes its own writes.
+func (t *Txn) Rollback() error {
+	if !t.active() {
+		return ErrTxnDone
+	}
+	defer t.done()
+	if t.parent != nil {
+		if _, err := t.tx.Exec("ROLLBACK TO SAVEPOINT " + t.savepoint + ";"); err != nil {
+			return err
+		}
+		_, err := t.tx.Exec("RELEASE SAVEPOINT " + t.savepoint + ";")
+		return err
+	}
+	return t.tx.Rollback()
+}
+
+// Marks the transaction finished and releases the Store's write lock, nested transactions leave the lock to their parent.
+func (t *Txn) done() {
+	t.tx = nil
+	if t.parent == nil {
+		t.store.mutex.Unlock()
+	}
+}
@@ -0,0 +1,22 @@
+package kvlite
+
+// TypedStore wraps a Store, storing and retrieving values of a single type T.
+type TypedStore[T any] struct {
+	store *Store
+}
+
+// Layers a TypedStore for values of type T over an existing Store.
+func Typed[T any](s *Store) *TypedStore[T] {
+	return &TypedStore[T]{store: s}
+}
+
+// Stores value in Store datastore.
+func (t *TypedStore[T]) Set(table, key string, val T) error {
+	return t.store.Set(table, key, val)
+}
+
+// Retreive a value at key in table specified.
+func (t *TypedStore[T]) Get(table, key string) (val T, found bool, err error) {
+	found, err = t.store.Get(table, key, &val)
+	return val, found, err
+}
@@ -0,0 +1,92 @@
+package kvlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrVersionConflict is returned by SetVersioned if the stored version of a key is not the version expected.
+var ErrVersionConflict = errors.New("kvlite: Stored version does not match expected version, unable to write.")
+
+// Retreive a value at key in table along with its version, which increases with every write to key.
+func (s *Store) GetVersioned(table, key string, out interface{}) (found bool, version int64, err error) {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	err = s.chkTable(&table, _reserved)
+	if err != nil {
+		return false, 0, err
+	}
+
+	data, eFlag, version, found, err := s.fetchVersion(context.Background(), s.dbCon, table, key)
+	if err != nil || !found {
+		return false, 0, err
+	}
+
+	return true, version, s.decode(table, key, data, eFlag, out)
+}
+
+// Stores value at key only if its stored version is expectedVersion, returning the new version.
+// A missing key has version 0, fails with ErrVersionConflict if key was written since expectedVersion was read.
+func (s *Store) SetVersioned(table, key string, val interface{}, expectedVersion int64) (newVersion int64, err error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err = s.chkTable(&table, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	value, eFlag, err := s.encode(val, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+
+	err = s.immediate(ctx, func(conn *sql.Conn) error {
+		_, _, version, _, err := s.fetchVersion(ctx, conn, table, key)
+		if err != nil {
+			return err
+		}
+		if version != expectedVersion {
+			return ErrVersionConflict
+		}
+		if err = s.put(ctx, conn, table, key, value, eFlag, 0); err != nil {
+			return err
+		}
+		if err = s.project(ctx, conn, table, key, val); err != nil {
+			return err
+		}
+		_, _, newVersion, _, err = s.fetchVersion(ctx, conn, table, key)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.notifyValue(table, key, EventSet, value, eFlag)
+	return newVersion, nil
+}
+
+// Reads encoded value and version at key from table, expired keys are treated as missing with version 0.
+func (s *Store) fetchVersion(ctx context.Context, db dbExec, table, key string) (data []byte, eFlag int, version int64, found bool, err error) {
+	var expires int64
+
+	err = db.QueryRowContext(ctx, "SELECT value, e, "+s.extCol(table, "expires_at")+", "+s.extCol(table, "version")+" FROM '"+table+"' WHERE key COLLATE "+s.collate()+" = ?;", key).Scan(&data, &eFlag, &expires, &version)
+
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, 0, 0, false, nil
+	case err != nil:
+		if isNoTable(err) {
+			return nil, 0, 0, false, nil
+		}
+		return nil, 0, 0, false, err
+	case expired(expires):
+		return nil, 0, 0, false, nil
+	}
+	return data, eFlag, version, true, nil
+}
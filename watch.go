@@ -0,0 +1,258 @@
+package kvlite
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// EventOp is the kind of change reported by an Event.
+type EventOp int
+
+const (
+	EventSet      EventOp = iota // Key was written.
+	EventUnset                   // Key was removed.
+	EventTruncate                // Table was dropped, reported only to subscribers.
+)
+
+// Event describes a change to a watched key.
+type Event struct {
+	Table string
+	Key   string
+	Op    EventOp
+}
+
+// Number of events buffered for each watcher before further events are dropped.
+const watchBuffer = 16
+
+type watcher struct {
+	table string
+	key   string
+	ch    chan Event
+}
+
+// Returns a channel receiving an Event each time key in table is Set or Unset through this Store, and a func to stop watching.
+// Changes made by other processes are not reported, events are dropped if the channel is not drained.
+func (s *Store) Watch(table, key string) (<-chan Event, func()) {
+	w := &watcher{table: table, key: key, ch: make(chan Event, watchBuffer)}
+
+	s.watchMutex.Lock()
+	if s.watchers == nil {
+		s.watchers = make(map[*watcher]struct{})
+	}
+	s.watchers[w] = struct{}{}
+	s.watchMutex.Unlock()
+
+	cancel := func() {
+		s.watchMutex.Lock()
+		defer s.watchMutex.Unlock()
+		if _, ok := s.watchers[w]; ok {
+			delete(s.watchers, w)
+			close(w.ch)
+		}
+	}
+
+	return w.ch, cancel
+}
+
+// Returns true if any watcher or subscriber would be told of a change, for writes that must look up the keys they change.
+func (s *Store) watched() bool {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+	return len(s.watchers) > 0 || len(s.subscribers) > 0
+}
+
+// Sends an Event to all watchers of key in table, and a Change to all subscribers.
+func (s *Store) notify(table string, key interface{}, op EventOp) {
+	s.notifyValue(table, key, op, nil, 0)
+}
+
+// Sends an Event to all watchers of key in table, and a Change to all subscribers carrying value as stored with its e column bits.
+func (s *Store) notifyValue(table string, key interface{}, op EventOp, value interface{}, eFlag int) {
+	var key_str string
+	if k, ok := key.([]byte); ok {
+		key_str = string(k)
+	} else {
+		key_str = fmt.Sprintf("%v", key)
+	}
+
+	s.watchMutex.Lock()
+	for w := range s.watchers {
+		if w.table != table || s.foldKey(w.key) != s.foldKey(key_str) {
+			continue
+		}
+		select {
+		case w.ch <- Event{Table: table, Key: key_str, Op: op}:
+		default:
+		}
+	}
+	s.watchMutex.Unlock()
+
+	s.publish(Change{Table: table, Key: key_str, Op: op}, value, eFlag)
+}
+
+// Change describes a write made through a Store, as received from Subscribe.
+type Change struct {
+	Table string   // Table changed.
+	Key   string   // Key changed, empty for EventTruncate.
+	Op    EventOp  // Kind of change.
+	Value []byte   // Value written by an EventSet as returned by GetRaw, only set with SubscribeOptions.Values.
+	Flags RawFlags // Encoding of Value, as returned by GetRaw.
+}
+
+// SubscribeOptions configure a change feed opened with SubscribeWithOptions.
+type SubscribeOptions struct {
+	Buffer int  // Number of changes buffered for the subscriber, defaults to 64.
+	Block  bool // Wait for the subscriber to make room once the buffer is full rather than drop the change, later changes queue meanwhile.
+	Values bool // Include the value written with each EventSet.
+}
+
+// Number of changes buffered for each subscriber unless SubscribeOptions.Buffer is set.
+const subscribeBuffer = 64
+
+type subscriber struct {
+	opts  SubscribeOptions
+	ch    chan Change
+	done  chan struct{}
+	mutex sync.RWMutex
+	once  sync.Once
+}
+
+// Returns a channel receiving a Change for every key written or removed and every table dropped through this Store, and a func to stop the feed.
+// Changes made by other processes, and tables renamed or copied whole, are not reported.
+// Changes are sent after the write completes, so subscribers may use the Store, they are dropped if the channel is not drained, see SubscribeWithOptions to block instead.
+func (s *Store) Subscribe() (<-chan Change, func()) {
+	return s.SubscribeWithOptions(SubscribeOptions{})
+}
+
+// Subscribe with the SubscribeOptions specified.
+func (s *Store) SubscribeWithOptions(opts SubscribeOptions) (<-chan Change, func()) {
+	if opts.Buffer <= 0 {
+		opts.Buffer = subscribeBuffer
+	}
+
+	sub := &subscriber{
+		opts: opts,
+		ch:   make(chan Change, opts.Buffer),
+		done: make(chan struct{}),
+	}
+
+	s.watchMutex.Lock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[*subscriber]struct{})
+	}
+	s.subscribers[sub] = struct{}{}
+	s.watchMutex.Unlock()
+
+	cancel := func() {
+		sub.once.Do(func() {
+			s.watchMutex.Lock()
+			delete(s.subscribers, sub)
+			s.watchMutex.Unlock()
+
+			// Release a blocked send before closing the channel under it.
+			close(sub.done)
+			sub.mutex.Lock()
+			close(sub.ch)
+			sub.mutex.Unlock()
+		})
+	}
+
+	return sub.ch, cancel
+}
+
+// A change waiting to be sent to the subscribers at the time it was made.
+type pendingChange struct {
+	change Change
+	value  interface{}
+	eFlag  int
+	subs   []*subscriber
+}
+
+// Queues change to be sent to all subscribers, with value as stored to those wanting values.
+// Called with the Store locked for writing, so changes queue in the order they were made.
+func (s *Store) publish(change Change, value interface{}, eFlag int) {
+	s.watchMutex.Lock()
+	defer s.watchMutex.Unlock()
+
+	if len(s.subscribers) == 0 {
+		return
+	}
+	subs := make([]*subscriber, 0, len(s.subscribers))
+	for sub := range s.subscribers {
+		subs = append(subs, sub)
+	}
+
+	s.pending = append(s.pending, pendingChange{change, value, eFlag, subs})
+	if !s.dispatching {
+		s.dispatching = true
+		go s.dispatch()
+	}
+}
+
+// Sends queued changes to their subscribers until none remain, without holding any lock of the Store.
+func (s *Store) dispatch() {
+	for {
+		s.watchMutex.Lock()
+		batch := s.pending
+		s.pending = nil
+		if len(batch) == 0 {
+			s.dispatching = false
+			s.watchMutex.Unlock()
+			return
+		}
+		s.watchMutex.Unlock()
+
+		for _, p := range batch {
+			for _, sub := range p.subs {
+				c := p.change
+				if sub.opts.Values && p.value != nil {
+					c.Value, c.Flags = storedBytes(p.value), RawFlags(p.eFlag)
+				}
+				sub.send(c)
+			}
+		}
+	}
+}
+
+// Sends c to the subscriber, dropping it if the buffer is full unless the subscriber blocks.
+func (sub *subscriber) send(c Change) {
+	sub.mutex.RLock()
+	defer sub.mutex.RUnlock()
+
+	select {
+	case <-sub.done:
+		return
+	default:
+	}
+
+	if sub.opts.Block {
+		select {
+		case sub.ch <- c:
+		case <-sub.done:
+		}
+		return
+	}
+
+	select {
+	case sub.ch <- c:
+	default:
+	}
+}
+
+// Returns a value as written to the value column in the form GetRaw reads it back.
+func storedBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return append([]byte(nil), v...)
+	case string:
+		return []byte(v)
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(nil, v, 10)
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64)
+	}
+	return nil
+}